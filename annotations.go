@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	annotationsGitHub = "github"
+	annotationsGitLab = "gitlab"
+
+	defaultGitLabCodeQualityFile = "gl-code-quality-report.json"
+)
+
+type (
+	// annotation points a test failure to a line of a source file.
+	annotation struct {
+		Path    string
+		Line    int
+		Title   string
+		Message string
+	}
+
+	gitLabCodeQualityIssue struct {
+		Description string                    `json:"description"`
+		CheckName   string                    `json:"check_name"`
+		Fingerprint string                    `json:"fingerprint"`
+		Severity    string                    `json:"severity"`
+		Location    gitLabCodeQualityLocation `json:"location"`
+	}
+
+	gitLabCodeQualityLocation struct {
+		Path  string                 `json:"path"`
+		Lines gitLabCodeQualityLines `json:"lines"`
+	}
+
+	gitLabCodeQualityLines struct {
+		Begin int `json:"begin"`
+	}
+)
+
+// collectAnnotations returns an annotation for every failure message of a failed test, except for
+// known failures. Failed tests without a message of their own are annotated at the test function,
+// unless one of their subtests failed and is annotated instead.
+func collectAnnotations(summary *jsonSummary) []annotation {
+	var annotations []annotation
+	for _, test := range summary.Tests {
+		if test.Status != "fail" || test.isKnownFailure() {
+			continue
+		}
+		title := fmt.Sprintf("%s (%s)", test.Name, test.Package)
+		dir := ""
+		if test.Location != nil && test.Location.Path != "" {
+			dir = path.Dir(test.Location.Path)
+		}
+		for _, failure := range test.Failures {
+			annotations = append(annotations, annotation{
+				Path:    path.Join(dir, failure.File),
+				Line:    failure.Line,
+				Title:   title,
+				Message: failure.Message,
+			})
+		}
+		if len(test.Failures) > 0 || summary.hasFailedSubtest(test) {
+			continue
+		}
+		a := annotation{Title: title, Message: fmt.Sprintf("%s failed", test.Name)}
+		if test.Location != nil {
+			a.Path = test.Location.Path
+			if a.Path == "" {
+				a.Path = test.Location.File
+			}
+			a.Line = test.Location.Line
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations
+}
+
+var (
+	gitHubDataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	gitHubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+// writeGitHubAnnotations prints the annotations as GitHub Actions "::error" workflow commands.
+func writeGitHubAnnotations(w io.Writer, annotations []annotation) error {
+	for _, a := range annotations {
+		var properties []string
+		if a.Path != "" {
+			properties = append(properties, "file="+gitHubPropertyEscaper.Replace(a.Path))
+			if a.Line > 0 {
+				properties = append(properties, fmt.Sprintf("line=%d", a.Line))
+			}
+		}
+		properties = append(properties, "title="+gitHubPropertyEscaper.Replace(a.Title))
+		if _, err := fmt.Fprintf(w, "::error %s::%s\n", strings.Join(properties, ","), gitHubDataEscaper.Replace(a.Message)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGitLabCodeQuality writes the annotations as a GitLab Code Quality report. Annotations
+// without a file are left out because GitLab requires a location for every issue.
+func writeGitLabCodeQuality(w io.Writer, annotations []annotation) error {
+	issues := []gitLabCodeQualityIssue{}
+	for _, a := range annotations {
+		if a.Path == "" {
+			continue
+		}
+		line := a.Line
+		if line < 1 {
+			line = 1
+		}
+		fingerprint := md5.Sum([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%s", a.Title, a.Path, line, a.Message)))
+		issues = append(issues, gitLabCodeQualityIssue{
+			Description: fmt.Sprintf("%s: %s", a.Title, a.Message),
+			CheckName:   "go-test-report",
+			Fingerprint: hex.EncodeToString(fingerprint[:]),
+			Severity:    "major",
+			Location: gitLabCodeQualityLocation{
+				Path:  a.Path,
+				Lines: gitLabCodeQualityLines{Begin: line},
+			},
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(issues)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func annotationsTestSummary() *jsonSummary {
+	return &jsonSummary{
+		Tests: []*jsonSummaryTest{
+			{Name: "TestA", Package: "pkg/a", Status: "fail",
+				Location: &jsonSummaryLocation{File: "a_test.go", Path: "pkg/a/a_test.go", Line: 10}},
+			{Name: "TestA/sub", Package: "pkg/a", Status: "fail",
+				Location: &jsonSummaryLocation{File: "a_test.go", Path: "pkg/a/a_test.go", Line: 10},
+				Failures: []failureMessage{{File: "helpers_test.go", Line: 7, Message: "want 1,\ngot 2: 100%"}}},
+			{Name: "TestB", Package: "pkg/b", Status: "fail",
+				Location: &jsonSummaryLocation{File: "b_test.go", Path: "pkg/b/b_test.go", Line: 3}},
+			{Name: "TestC", Package: "pkg/c", Status: "fail"},
+			{Name: "TestD", Package: "pkg/c", Status: "pass"},
+		},
+	}
+}
+
+func TestCollectAnnotations(t *testing.T) {
+	assertions := assert.New(t)
+	annotations := collectAnnotations(annotationsTestSummary())
+	assertions.Equal([]annotation{
+		{Path: "pkg/a/helpers_test.go", Line: 7, Title: "TestA/sub (pkg/a)", Message: "want 1,\ngot 2: 100%"},
+		{Path: "pkg/b/b_test.go", Line: 3, Title: "TestB (pkg/b)", Message: "TestB failed"},
+		{Title: "TestC (pkg/c)", Message: "TestC failed"},
+	}, annotations)
+}
+
+func TestWriteGitHubAnnotations(t *testing.T) {
+	assertions := assert.New(t)
+	buffer := &bytes.Buffer{}
+	assertions.Nil(writeGitHubAnnotations(buffer, collectAnnotations(annotationsTestSummary())))
+	assertions.Equal("::error file=pkg/a/helpers_test.go,line=7,title=TestA/sub (pkg/a)::want 1,%0Agot 2: 100%25\n"+
+		"::error file=pkg/b/b_test.go,line=3,title=TestB (pkg/b)::TestB failed\n"+
+		"::error title=TestC (pkg/c)::TestC failed\n", buffer.String())
+}
+
+func TestWriteGitLabCodeQuality(t *testing.T) {
+	assertions := assert.New(t)
+	buffer := &bytes.Buffer{}
+	assertions.Nil(writeGitLabCodeQuality(buffer, collectAnnotations(annotationsTestSummary())))
+	var issues []gitLabCodeQualityIssue
+	assertions.Nil(json.Unmarshal(buffer.Bytes(), &issues))
+	assertions.Len(issues, 2)
+	assertions.Equal("pkg/a/helpers_test.go", issues[0].Location.Path)
+	assertions.Equal(7, issues[0].Location.Lines.Begin)
+	assertions.Equal("major", issues[0].Severity)
+	assertions.Len(issues[0].Fingerprint, 32)
+	assertions.NotEqual(issues[0].Fingerprint, issues[1].Fingerprint)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ansiEscapeRegExp matches ANSI CSI sequences, e.g. colors and cursor movements, and OSC sequences,
+// e.g. hyperlinks. Only SGR sequences, the CSI sequences ending in m, are rendered.
+var ansiEscapeRegExp = regexp.MustCompile(`\x1b\[([0-9;:?]*)([@-~])|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)?`)
+
+// ansiStyle is the text style set by the SGR sequences so far. The colors are class names of the
+// 256 color palette, e.g. ansiFg208; true colors are shown as the nearest palette color, since the
+// Content-Security-Policy of the report does not allow style attributes.
+type ansiStyle struct {
+	bold, faint, italic, underline bool
+	fg, bg                         string
+}
+
+// stripColors removes the ANSI escape sequences from text.
+func stripColors(text string) string {
+	if !strings.Contains(text, "\x1b") {
+		return text
+	}
+	return ansiEscapeRegExp.ReplaceAllString(text, "")
+}
+
+// ansiCubeLevels are the channel values of the 6x6x6 color cube of the 256 color palette.
+var ansiCubeLevels = []int{0, 95, 135, 175, 215, 255}
+
+// ansiRGB returns the red, green and blue values of a color index of the 256 color palette from 16
+// on; the first 16 colors are styled by the report.
+func ansiRGB(index int) (int, int, int) {
+	if index < 232 {
+		index -= 16
+		return ansiCubeLevels[index/36], ansiCubeLevels[index/6%6], ansiCubeLevels[index%6]
+	}
+	gray := 8 + (index-232)*10
+	return gray, gray, gray
+}
+
+// nearestANSIColor returns the index of the color of the 256 color palette, from 16 on, closest
+// to a true color.
+func nearestANSIColor(r, g, b int) int {
+	nearest, distance := 0, -1
+	for index := 16; index < 256; index++ {
+		pr, pg, pb := ansiRGB(index)
+		if d := (r-pr)*(r-pr) + (g-pg)*(g-pg) + (b-pb)*(b-pb); distance < 0 || d < distance {
+			nearest, distance = index, d
+		}
+	}
+	return nearest
+}
+
+// ansiPaletteCSS returns the CSS classes of the colors 16 to 255 of the 256 color palette.
+func ansiPaletteCSS() template.CSS {
+	b := &strings.Builder{}
+	for index := 16; index < 256; index++ {
+		r, g, bl := ansiRGB(index)
+		fmt.Fprintf(b, ".console .ansiFg%d { color: #%02x%02x%02x; }\n", index, r, g, bl)
+		fmt.Fprintf(b, ".console .ansiBg%d { background-color: #%02x%02x%02x; }\n", index, r, g, bl)
+	}
+	return template.CSS(b.String())
+}
+
+// extendedColor parses the 256 color (5;n) or true color (2;r;g;b) arguments following 38 or 48
+// and returns the color class and the number of arguments used.
+func extendedColor(params []int, prefix string) (string, int) {
+	if len(params) >= 2 && params[0] == 5 && params[1] >= 0 && params[1] < 256 {
+		return fmt.Sprintf("%s%d", prefix, params[1]), 2
+	}
+	if len(params) >= 4 && params[0] == 2 {
+		rgb := params[1:4]
+		for _, c := range rgb {
+			if c < 0 || c > 255 {
+				return "", 4
+			}
+		}
+		return fmt.Sprintf("%s%d", prefix, nearestANSIColor(rgb[0], rgb[1], rgb[2])), 4
+	}
+	return "", len(params)
+}
+
+// apply updates the style with the parameters of an SGR sequence.
+func (s *ansiStyle) apply(arguments string) {
+	var params []int
+	for _, param := range strings.FieldsFunc(arguments, func(r rune) bool { return r == ';' || r == ':' }) {
+		n, _ := strconv.Atoi(param)
+		params = append(params, n)
+	}
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	for i := 0; i < len(params); i++ {
+		switch p := params[i]; {
+		case p == 0:
+			*s = ansiStyle{}
+		case p == 1:
+			s.bold = true
+		case p == 2:
+			s.faint = true
+		case p == 3:
+			s.italic = true
+		case p == 4:
+			s.underline = true
+		case p == 22:
+			s.bold, s.faint = false, false
+		case p == 23:
+			s.italic = false
+		case p == 24:
+			s.underline = false
+		case p >= 30 && p <= 37:
+			s.fg = fmt.Sprintf("ansiFg%d", p-30)
+		case p >= 90 && p <= 97:
+			s.fg = fmt.Sprintf("ansiFg%d", p-90+8)
+		case p == 39:
+			s.fg = ""
+		case p >= 40 && p <= 47:
+			s.bg = fmt.Sprintf("ansiBg%d", p-40)
+		case p >= 100 && p <= 107:
+			s.bg = fmt.Sprintf("ansiBg%d", p-100+8)
+		case p == 49:
+			s.bg = ""
+		case p == 38 || p == 48:
+			prefix := "ansiFg"
+			if p == 48 {
+				prefix = "ansiBg"
+			}
+			color, n := extendedColor(params[i+1:], prefix)
+			if p == 38 {
+				s.fg = color
+			} else {
+				s.bg = color
+			}
+			i += n
+		}
+	}
+}
+
+// span returns the opening tag of a span with the classes of the style, or an empty string for the
+// default style.
+func (s *ansiStyle) span() string {
+	var classes []string
+	for _, flag := range []struct {
+		set   bool
+		class string
+	}{{s.bold, "ansiBold"}, {s.faint, "ansiFaint"}, {s.italic, "ansiItalic"}, {s.underline, "ansiUnderline"}} {
+		if flag.set {
+			classes = append(classes, flag.class)
+		}
+	}
+	for _, color := range []string{s.fg, s.bg} {
+		if color != "" {
+			classes = append(classes, color)
+		}
+	}
+	if len(classes) == 0 {
+		return ""
+	}
+	return `<span class="` + strings.Join(classes, " ") + `">`
+}
+
+// ansiHTMLWriter writes text as HTML with its ANSI SGR sequences turned into styled spans. Other
+// escape sequences are removed. The style carries over between writes, so that the output of a
+// test can be written line by line.
+type ansiHTMLWriter struct {
+	strings.Builder
+	style ansiStyle
+	open  string
+}
+
+func (w *ansiHTMLWriter) writeSegment(text string) {
+	if text == "" {
+		return
+	}
+	w.WriteString(w.open)
+	w.WriteString(html.EscapeString(text))
+	if w.open != "" {
+		w.WriteString("</span>")
+	}
+}
+
+func (w *ansiHTMLWriter) writeText(text string) {
+	if !strings.Contains(text, "\x1b") {
+		w.writeSegment(text)
+		return
+	}
+	last := 0
+	for _, m := range ansiEscapeRegExp.FindAllStringSubmatchIndex(text, -1) {
+		w.writeSegment(text[last:m[0]])
+		last = m[1]
+		if m[4] >= 0 && text[m[4]:m[5]] == "m" {
+			w.style.apply(text[m[2]:m[3]])
+			w.open = w.style.span()
+		}
+	}
+	w.writeSegment(text[last:])
+}
+
+// ansiToHTML escapes text as HTML and turns its ANSI SGR sequences into styled spans.
+func ansiToHTML(text string) string {
+	w := &ansiHTMLWriter{}
+	w.writeText(text)
+	return w.String()
+}
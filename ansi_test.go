@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripColors(t *testing.T) {
+	assertions := assert.New(t)
+	assertions.Equal("plain", stripColors("plain"))
+	assertions.Equal("FAIL ok", stripColors("\x1b[1;31mFAIL\x1b[0m \x1b[2Kok"))
+	assertions.Equal("link", stripColors("\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\"))
+}
+
+func TestAnsiToHTML(t *testing.T) {
+	assertions := assert.New(t)
+	assertions.Equal("a &lt;b&gt; &amp; &#34;c&#34;", ansiToHTML(`a <b> & "c"`))
+	assertions.Equal(`<span class="ansiFg1">red</span> plain`, ansiToHTML("\x1b[31mred\x1b[0m plain"))
+	assertions.Equal(`<span class="ansiBold ansiFg9">&lt;x&gt;</span><span class="ansiFg9">y</span>`,
+		ansiToHTML("\x1b[1;91m<x>\x1b[22my\x1b[m"))
+	assertions.Equal(`<span class="ansiBg4">blue</span>`, ansiToHTML("\x1b[44mblue\x1b[49m"))
+	assertions.Equal(`<span class="ansiFg208">256</span>`, ansiToHTML("\x1b[38;5;208m256\x1b[39m"))
+	// true colors are shown as the nearest color of the palette
+	assertions.Equal(`<span class="ansiFg3 ansiBg233">rgb</span>`,
+		ansiToHTML("\x1b[48;2;10;20;30;33mrgb\x1b[0m"))
+	assertions.Equal(`<span class="ansiFg208">rgb</span>`, ansiToHTML("\x1b[38;2;250;130;10mrgb"))
+	assertions.Equal("cleared", ansiToHTML("\x1b[2Kcleared"))
+}
+
+func TestAnsiPaletteCSS(t *testing.T) {
+	assertions := assert.New(t)
+	css := string(ansiPaletteCSS())
+	assertions.Contains(css, ".console .ansiFg16 { color: #000000; }\n")
+	assertions.Contains(css, ".console .ansiFg208 { color: #ff8700; }\n")
+	assertions.Contains(css, ".console .ansiBg255 { background-color: #eeeeee; }\n")
+	assertions.NotContains(css, "ansiFg15 ")
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The Common Test Report Format, see https://ctrf.io
+type (
+	ctrfReport struct {
+		ReportFormat string      `json:"reportFormat"`
+		SpecVersion  string      `json:"specVersion"`
+		Results      ctrfResults `json:"results"`
+	}
+
+	ctrfResults struct {
+		Tool    ctrfTool    `json:"tool"`
+		Summary ctrfSummary `json:"summary"`
+		Tests   []*ctrfTest `json:"tests"`
+	}
+
+	ctrfTool struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	ctrfSummary struct {
+		Tests   int   `json:"tests"`
+		Passed  int   `json:"passed"`
+		Failed  int   `json:"failed"`
+		Pending int   `json:"pending"`
+		Skipped int   `json:"skipped"`
+		Other   int   `json:"other"`
+		Start   int64 `json:"start"`
+		Stop    int64 `json:"stop"`
+	}
+
+	ctrfTest struct {
+		Name      string            `json:"name"`
+		Status    string            `json:"status"`
+		Duration  int64             `json:"duration"`
+		Suite     string            `json:"suite,omitempty"`
+		Message   string            `json:"message,omitempty"`
+		Trace     string            `json:"trace,omitempty"`
+		RawStatus string            `json:"rawStatus"`
+		FilePath  string            `json:"filePath,omitempty"`
+		Line      int               `json:"line,omitempty"`
+		Extra     map[string]string `json:"extra,omitempty"`
+	}
+)
+
+var ctrfStatus = map[string]string{
+	"pass": "passed",
+	"fail": "failed",
+	"skip": "skipped",
+}
+
+// newCTRFReport converts the summary to CTRF. The suite of a test is its package followed by the
+// names of its parent tests, separated by " > ". Durations are in milliseconds.
+func newCTRFReport(summary *jsonSummary) *ctrfReport {
+	stop := summary.GeneratedAt.UnixMilli()
+	report := &ctrfReport{
+		ReportFormat: "CTRF",
+		SpecVersion:  "0.0.0",
+		Results: ctrfResults{
+			Tool: ctrfTool{Name: "go-test-report", Version: version},
+			Summary: ctrfSummary{
+				Tests:   summary.Totals.Total,
+				Passed:  summary.Totals.Passed,
+				Failed:  summary.Totals.Failed,
+				Skipped: summary.Totals.Skipped,
+				Other:   summary.Totals.Known,
+				Start:   stop - int64(summary.Elapsed*1000),
+				Stop:    stop,
+			},
+			Tests: []*ctrfTest{},
+		},
+	}
+	for _, test := range summary.Tests {
+		names := strings.Split(test.Name, "/")
+		t := &ctrfTest{
+			Name:      test.Name,
+			Status:    ctrfStatus[test.Status],
+			Duration:  int64(test.Elapsed * 1000),
+			Suite:     strings.Join(append([]string{test.Package}, names[:len(names)-1]...), " > "),
+			RawStatus: test.Status,
+			Extra:     test.Metadata,
+		}
+		if test.Location != nil {
+			t.FilePath = test.Location.Path
+			if t.FilePath == "" {
+				t.FilePath = test.Location.File
+			}
+			t.Line = test.Location.Line
+		}
+		if test.isKnownFailure() {
+			t.Status = "other"
+		}
+		switch {
+		case len(test.Failures) > 0:
+			t.Message = test.Failures[0].Message
+			var trace []string
+			for _, failure := range test.Failures {
+				trace = append(trace, fmt.Sprintf("%s:%d: %s", failure.File, failure.Line, failure.Message))
+			}
+			t.Trace = strings.Join(trace, "\n")
+		case test.SkipReason != "":
+			t.Message = test.SkipReason
+		}
+		report.Results.Tests = append(report.Results.Tests, t)
+	}
+	// a package that failed outside of its tests is reported as a failed test named after it
+	for _, pkg := range summary.failedPackages() {
+		report.Results.Tests = append(report.Results.Tests, &ctrfTest{
+			Name:      pkg.Name,
+			Status:    "failed",
+			Duration:  int64(pkg.Elapsed * 1000),
+			Suite:     pkg.Name,
+			Message:   pkg.failureText(),
+			Trace:     pkg.Output,
+			RawStatus: "fail",
+		})
+		report.Results.Summary.Tests++
+		report.Results.Summary.Failed++
+	}
+	return report
+}
+
+func writeCTRF(w io.Writer, summary *jsonSummary) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(newCTRFReport(summary))
+}
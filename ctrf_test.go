@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCTRFReport(t *testing.T) {
+	assertions := assert.New(t)
+	summary := &jsonSummary{
+		GeneratedAt: time.UnixMilli(10000),
+		Elapsed:     2.5,
+		Totals:      jsonSummaryCounts{Total: 2, Failed: 1, Skipped: 1},
+		Tests: []*jsonSummaryTest{
+			{Name: "TestA/sub/deep", Package: "pkg/a", Status: "fail", Elapsed: 0.25,
+				Location: &jsonSummaryLocation{File: "a_test.go", Path: "pkg/a/a_test.go", Line: 10},
+				Failures: []failureMessage{{File: "a_test.go", Line: 12, Message: "boom"}, {File: "a_test.go", Line: 13, Message: "again"}}},
+			{Name: "TestB", Package: "pkg/b", Status: "skip", SkipReason: "needs docker"},
+		},
+	}
+	report := newCTRFReport(summary)
+	assertions.Equal("CTRF", report.ReportFormat)
+	assertions.Equal(ctrfSummary{Tests: 2, Failed: 1, Skipped: 1, Start: 7500, Stop: 10000}, report.Results.Summary)
+	assertions.Len(report.Results.Tests, 2)
+	assertions.Equal(&ctrfTest{
+		Name:      "TestA/sub/deep",
+		Status:    "failed",
+		Duration:  250,
+		Suite:     "pkg/a > TestA > sub",
+		Message:   "boom",
+		Trace:     "a_test.go:12: boom\na_test.go:13: again",
+		RawStatus: "fail",
+		FilePath:  "pkg/a/a_test.go",
+		Line:      10,
+	}, report.Results.Tests[0])
+	assertions.Equal("skipped", report.Results.Tests[1].Status)
+	assertions.Equal("pkg/b", report.Results.Tests[1].Suite)
+	assertions.Equal("needs docker", report.Results.Tests[1].Message)
+}
+
+func TestNewCTRFReportPackageFailures(t *testing.T) {
+	assertions := assert.New(t)
+	report := newCTRFReport(buildFailureSummary(t))
+	assertions.Equal(3, report.Results.Summary.Tests)
+	assertions.Equal(1, report.Results.Summary.Passed)
+	assertions.Equal(2, report.Results.Summary.Failed)
+	assertions.Len(report.Results.Tests, 3)
+	broken := report.Results.Tests[1]
+	assertions.Equal("example.com/bf/broken", broken.Name)
+	assertions.Equal("failed", broken.Status)
+	assertions.Equal("example.com/bf/broken", broken.Suite)
+	assertions.Equal("build failed: example.com/bf/broken [example.com/bf/broken.test]", broken.Message)
+	assertions.Contains(broken.Trace, "undefined: undefinedCall")
+	mainFail := report.Results.Tests[2]
+	assertions.Equal("example.com/bf/mainfail", mainFail.Name)
+	assertions.Equal(int64(2), mainFail.Duration)
+	assertions.Equal("package failed outside of its tests", mainFail.Message)
+	assertions.Contains(mainFail.Trace, "setup failed\n")
+}
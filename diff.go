@@ -0,0 +1,281 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed diff_report.html.template
+var diffReportHTMLTemplateStr []byte
+
+const (
+	diffPrintText     = "text"
+	diffPrintMarkdown = "markdown"
+	diffPrintNone     = "none"
+)
+
+type (
+	// testDiff is a test in the base and the head run. The status of a side is empty if the test
+	// was not run there.
+	testDiff struct {
+		Package     string
+		Name        string
+		BaseStatus  string
+		HeadStatus  string
+		BaseElapsed float64
+		HeadElapsed float64
+		Failures    []failureMessage
+	}
+
+	// diffCategory is a named list of tests of the run diff.
+	diffCategory struct {
+		Title string
+		Class string
+		Tests []*testDiff
+	}
+
+	// runDiff classifies the tests of the head run by how they changed since the base run.
+	runDiff struct {
+		Title             string
+		BaseFilename      string
+		HeadFilename      string
+		NewlyFailing      []*testDiff
+		StillFailing      []*testDiff
+		Fixed             []*testDiff
+		NewlySkipped      []*testDiff
+		New               []*testDiff
+		Removed           []*testDiff
+		SlowerTests       []*testDiff
+		RegressionFactor  float64
+		RegressionMinimum time.Duration
+	}
+)
+
+// DurationChange returns the relative change of the duration, e.g. "+150%".
+func (d *testDiff) DurationChange() string {
+	return durationChange(d.BaseElapsed, d.HeadElapsed)
+}
+
+// Categories returns the non-empty categories of the diff, most important first.
+func (d *runDiff) Categories() []diffCategory {
+	var categories []diffCategory
+	for _, c := range []diffCategory{
+		{"Newly failing", "newlyFailing", d.NewlyFailing},
+		{"Still failing", "stillFailing", d.StillFailing},
+		{"Fixed", "fixed", d.Fixed},
+		{"Newly skipped", "newlySkipped", d.NewlySkipped},
+		{"New", "new", d.New},
+		{"Removed", "removed", d.Removed},
+	} {
+		if len(c.Tests) > 0 {
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
+
+// diffRuns compares the tests of two runs. A test that fails in head but did not fail in base,
+// including a new test, is newly failing. Passed tests of both runs whose duration grew by at
+// least the factor and the minimum are duration regressions.
+func diffRuns(base, head map[string]*testStatus, factor float64, minimum time.Duration) *runDiff {
+	d := &runDiff{RegressionFactor: factor, RegressionMinimum: minimum}
+	for _, status := range sortedTests(head) {
+		key := status.Package + "." + status.TestName
+		td := &testDiff{
+			Package:     status.Package,
+			Name:        status.TestName,
+			HeadStatus:  status.statusText(),
+			HeadElapsed: status.ElapsedTime,
+		}
+		if b, exists := base[key]; exists {
+			td.BaseStatus = b.statusText()
+			td.BaseElapsed = b.ElapsedTime
+		}
+		switch {
+		case td.HeadStatus == "fail" && td.BaseStatus == "fail":
+			td.Failures = parseFailureMessages(status.Output)
+			d.StillFailing = append(d.StillFailing, td)
+		case td.HeadStatus == "fail":
+			td.Failures = parseFailureMessages(status.Output)
+			d.NewlyFailing = append(d.NewlyFailing, td)
+		case td.BaseStatus == "":
+			d.New = append(d.New, td)
+		case td.HeadStatus == "skip" && td.BaseStatus != "skip":
+			d.NewlySkipped = append(d.NewlySkipped, td)
+		case td.HeadStatus == "pass" && td.BaseStatus == "fail":
+			d.Fixed = append(d.Fixed, td)
+		case td.HeadStatus == "pass" && td.BaseStatus == "pass":
+			if isDurationRegression(td.BaseElapsed, td.HeadElapsed, factor, minimum) {
+				d.SlowerTests = append(d.SlowerTests, td)
+			}
+		}
+	}
+	for _, status := range sortedTests(base) {
+		if _, exists := head[status.Package+"."+status.TestName]; !exists {
+			d.Removed = append(d.Removed, &testDiff{
+				Package:     status.Package,
+				Name:        status.TestName,
+				BaseStatus:  status.statusText(),
+				BaseElapsed: status.ElapsedTime,
+			})
+		}
+	}
+	sort.SliceStable(d.SlowerTests, func(i, j int) bool {
+		return d.SlowerTests[i].HeadElapsed-d.SlowerTests[i].BaseElapsed > d.SlowerTests[j].HeadElapsed-d.SlowerTests[j].BaseElapsed
+	})
+	return d
+}
+
+func diffStatusText(status string) string {
+	if status == "" {
+		return "-"
+	}
+	return strings.ToUpper(status)
+}
+
+func writeDiffText(w io.Writer, d *runDiff) error {
+	b := &strings.Builder{}
+	for _, c := range d.Categories() {
+		fmt.Fprintf(b, "%s (%d):\n", c.Title, len(c.Tests))
+		for _, t := range c.Tests {
+			fmt.Fprintf(b, "  %s -> %s  %s  %s\n", diffStatusText(t.BaseStatus), diffStatusText(t.HeadStatus), t.Package, t.Name)
+			for _, failure := range t.Failures {
+				fmt.Fprintf(b, "      %s:%d: %s\n", failure.File, failure.Line, strings.ReplaceAll(failure.Message, "\n", "\n      "))
+			}
+		}
+	}
+	if len(d.SlowerTests) > 0 {
+		fmt.Fprintf(b, "Duration regressions (%d):\n", len(d.SlowerTests))
+		for _, t := range d.SlowerTests {
+			fmt.Fprintf(b, "  %.2fs -> %.2fs (%s)  %s  %s\n", t.BaseElapsed, t.HeadElapsed, t.DurationChange(), t.Package, t.Name)
+		}
+	}
+	fmt.Fprintf(b, "%d newly failing, %d still failing, %d fixed, %d newly skipped, %d new, %d removed, %d slower\n",
+		len(d.NewlyFailing), len(d.StillFailing), len(d.Fixed), len(d.NewlySkipped), len(d.New), len(d.Removed), len(d.SlowerTests))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func renderDiffMarkdown(d *runDiff, maxSize int) string {
+	md := &markdownWriter{maxSize: maxSize}
+	status := "✅"
+	if len(d.NewlyFailing) > 0 {
+		status = "❌"
+	}
+	md.keep(fmt.Sprintf("## %s %s\n\n", status, markdownEscape(d.Title)))
+	md.keep("| Newly failing | Still failing | Fixed | Newly skipped | New | Removed | Slower |\n| ---: | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+	md.keep(fmt.Sprintf("| %d | %d | %d | %d | %d | %d | %d |\n",
+		len(d.NewlyFailing), len(d.StillFailing), len(d.Fixed), len(d.NewlySkipped), len(d.New), len(d.Removed), len(d.SlowerTests)))
+	for _, c := range d.Categories() {
+		md.section(fmt.Sprintf("\n### %s (%d)\n\n", c.Title, len(c.Tests)))
+		for _, t := range c.Tests {
+			if len(t.Failures) == 0 {
+				md.write(fmt.Sprintf("- `%s` in `%s` (%s → %s)\n", markdownEscape(t.Name), markdownEscape(t.Package), diffStatusText(t.BaseStatus), diffStatusText(t.HeadStatus)))
+				continue
+			}
+			md.write(renderMarkdownFailure(&jsonSummaryTest{Name: t.Name, Package: t.Package, Failures: t.Failures}))
+		}
+	}
+	if len(d.SlowerTests) > 0 {
+		md.section(fmt.Sprintf("\n### Duration regressions (%d)\n\n| Test | Package | Base | Head | Change |\n| --- | --- | ---: | ---: | ---: |\n", len(d.SlowerTests)))
+		for _, t := range d.SlowerTests {
+			md.write(fmt.Sprintf("| `%s` | `%s` | %.2fs | %.2fs | %s |\n", markdownEscape(t.Name), markdownEscape(t.Package), t.BaseElapsed, t.HeadElapsed, t.DurationChange()))
+		}
+	}
+	return md.String()
+}
+
+func renderDiffHTML(w io.Writer, d *runDiff) error {
+	tpl, err := template.New("diff_report.html.template").Parse(string(diffReportHTMLTemplateStr))
+	if err != nil {
+		return err
+	}
+	nonce, err := newCSPNonce()
+	if err != nil {
+		return err
+	}
+	// the stylesheet carries the nonce of the Content-Security-Policy
+	return tpl.Execute(w, struct {
+		*runDiff
+		CSPNonce string
+	}{d, nonce})
+}
+
+// newDiffCommand creates the diff subcommand, which compares the tests of two go test -json runs.
+func newDiffCommand(flags *cmdFlags) *cobra.Command {
+	var baseFile, headFile, htmlFile, printFormat string
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "compare the tests of two go test -json runs",
+		Long: "compare the tests of two go test -json runs and list the tests that are newly failing, still failing, " +
+			"fixed, newly skipped, new or removed in the head run, and the tests that got slower",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if printFormat != diffPrintText && printFormat != diffPrintMarkdown && printFormat != diffPrintNone {
+				return fmt.Errorf("unknown --print format %q; expected %s, %s or %s", printFormat, diffPrintText, diffPrintMarkdown, diffPrintNone)
+			}
+			if err := validateRegressionFlags(flags); err != nil {
+				return err
+			}
+			redactor, err := newRedactor(flags)
+			if err != nil {
+				return err
+			}
+			_, base, err := readTestDataFromFile(baseFile, flags, cmd, redactor, nil)
+			if err != nil {
+				return fmt.Errorf("failed to read base file %s: %w", baseFile, err)
+			}
+			_, head, err := readTestDataFromFile(headFile, flags, cmd, redactor, nil)
+			if err != nil {
+				return fmt.Errorf("failed to read head file %s: %w", headFile, err)
+			}
+			redactor.redactTests(base)
+			redactor.redactTests(head)
+			d := diffRuns(base, head, flags.regressionFactor, flags.regressionMin)
+			d.Title = "test diff"
+			if cmd.Flags().Changed("title") {
+				d.Title = flags.titleFlag
+			}
+			d.BaseFilename = baseFile
+			d.HeadFilename = headFile
+			if htmlFile != "" {
+				if err := writeOutputFile(htmlFile, func(w io.Writer) error { return renderDiffHTML(w, d) }); err != nil {
+					return err
+				}
+			}
+			switch printFormat {
+			case diffPrintText:
+				return writeDiffText(cmd.OutOrStdout(), d)
+			case diffPrintMarkdown:
+				_, err := io.WriteString(cmd.OutOrStdout(), renderDiffMarkdown(d, flags.markdownMaxSize))
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&baseFile,
+		"base",
+		"",
+		"the go test -json output of the base run")
+	cmd.Flags().StringVar(&headFile,
+		"head",
+		"",
+		"the go test -json output of the head run")
+	cmd.MarkFlagRequired("base")
+	cmd.MarkFlagRequired("head")
+	cmd.Flags().StringVar(&htmlFile,
+		"html",
+		"",
+		"the HTML output file of the diff")
+	cmd.Flags().StringVar(&printFormat,
+		"print",
+		diffPrintText,
+		"the format of the diff printed to stdout; text, markdown or none")
+	return cmd
+}
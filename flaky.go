@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// flakyPanelSize is the maximum number of tests shown in the flaky tests panel of the report.
+const flakyPanelSize = 20
+
+// flakyTest is the flakiness of a test over the recent runs of the history: its score is the
+// number of flips over the number of comparisons between runs.
+type flakyTest struct {
+	Package     string  `json:"package"`
+	Name        string  `json:"name"`
+	Score       float64 `json:"score"`
+	Flips       int     `json:"flips"`
+	Comparisons int     `json:"comparisons"`
+	Runs        int     `json:"runs"`
+}
+
+// flakinessRanking scores every test by the fraction of status flips over the records, highest
+// score first. Only consecutive runs of the same commit are compared, as a flip after a code change
+// is more likely a fix or a regression. A test that both failed and passed within one run counts
+// as a flip of that run. Skipped runs are ignored, and runs of an unknown commit are not compared.
+// Tests that never flipped are left out.
+func flakinessRanking(records []*historyRecord) []*flakyTest {
+	type lastRun struct {
+		status string
+		commit string
+	}
+	tests := map[string]*flakyTest{}
+	last := map[string]lastRun{}
+	for _, record := range records {
+		for _, test := range record.Tests {
+			if test.Status == "skip" {
+				continue
+			}
+			key := test.Package + "." + test.Name
+			ft, exists := tests[key]
+			if !exists {
+				ft = &flakyTest{Package: test.Package, Name: test.Name}
+				tests[key] = ft
+			}
+			ft.Runs++
+			if test.Flaky {
+				ft.Flips++
+				ft.Comparisons++
+			}
+			if previous, exists := last[key]; exists && record.Commit != "" && previous.commit == record.Commit {
+				ft.Comparisons++
+				if previous.status != test.Status {
+					ft.Flips++
+				}
+			}
+			last[key] = lastRun{status: test.Status, commit: record.Commit}
+		}
+	}
+	var ranking []*flakyTest
+	for _, ft := range tests {
+		if ft.Flips == 0 {
+			continue
+		}
+		ft.Score = float64(ft.Flips) / float64(ft.Comparisons)
+		ranking = append(ranking, ft)
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Score != ranking[j].Score {
+			return ranking[i].Score > ranking[j].Score
+		}
+		if ranking[i].Package != ranking[j].Package {
+			return ranking[i].Package < ranking[j].Package
+		}
+		return ranking[i].Name < ranking[j].Name
+	})
+	return ranking
+}
+
+func writeFlakyRanking(w io.Writer, ranking []*flakyTest, asJSON bool) error {
+	if asJSON {
+		if ranking == nil {
+			ranking = []*flakyTest{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(ranking)
+	}
+	for _, ft := range ranking {
+		if _, err := fmt.Fprintf(w, "%.2f\t%d/%d\t%s\t%s\n", ft.Score, ft.Flips, ft.Comparisons, ft.Package, ft.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newFlakyCommand creates the flaky subcommand, which prints the flakiness ranking of the tests in
+// the run history.
+func newFlakyCommand(flags *cmdFlags) *cobra.Command {
+	var minScore float64
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "flaky",
+		Short: "print the tests of the run history ranked by flakiness",
+		Long: "print the tests of the run history ranked by flakiness, one test per line as: " +
+			"score, flips/comparisons, package and test name, separated by tabs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.historyDir == "" {
+				return errors.New("--history-dir is required")
+			}
+			if flags.historyRuns < 1 {
+				return fmt.Errorf("--history-runs must be at least 1, got %d", flags.historyRuns)
+			}
+			records, err := readHistory(filepath.Join(flags.historyDir, historyFileName), flags.historyRuns)
+			if err != nil {
+				return err
+			}
+			var ranking []*flakyTest
+			for _, ft := range flakinessRanking(records) {
+				if ft.Score >= minScore {
+					ranking = append(ranking, ft)
+				}
+			}
+			return writeFlakyRanking(cmd.OutOrStdout(), ranking, asJSON)
+		},
+	}
+	cmd.Flags().Float64Var(&minScore,
+		"min-score",
+		0,
+		"only print tests with at least this flakiness score, between 0 and 1")
+	cmd.Flags().BoolVar(&asJSON,
+		"json",
+		false,
+		"print the ranking as JSON")
+	return cmd
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlakinessRanking(t *testing.T) {
+	assertions := assert.New(t)
+	run := func(commit string, tests ...historyTest) *historyRecord {
+		return &historyRecord{Commit: commit, Tests: tests}
+	}
+	records := []*historyRecord{
+		run("c1", historyTest{Package: "pkg", Name: "TestA", Status: "pass"}, historyTest{Package: "pkg", Name: "TestB", Status: "pass"}),
+		run("c1", historyTest{Package: "pkg", Name: "TestA", Status: "fail"}, historyTest{Package: "pkg", Name: "TestB", Status: "skip"}),
+		// the code changed, so neither test is compared with the previous run
+		run("c2", historyTest{Package: "pkg", Name: "TestA", Status: "pass"}, historyTest{Package: "pkg", Name: "TestB", Status: "fail"}),
+		run("c2", historyTest{Package: "pkg", Name: "TestA", Status: "pass"}, historyTest{Package: "pkg", Name: "TestC", Status: "pass", Flaky: true}),
+		// the commit of these runs is unknown, so they are not compared
+		run("", historyTest{Package: "pkg", Name: "TestA", Status: "fail"}),
+		run("", historyTest{Package: "pkg", Name: "TestA", Status: "pass"}),
+	}
+	ranking := flakinessRanking(records)
+	assertions.Equal([]*flakyTest{
+		{Package: "pkg", Name: "TestC", Score: 1, Flips: 1, Comparisons: 1, Runs: 1},
+		{Package: "pkg", Name: "TestA", Score: 0.5, Flips: 1, Comparisons: 2, Runs: 6},
+	}, ranking)
+
+	b := &bytes.Buffer{}
+	assertions.Nil(writeFlakyRanking(b, ranking, false))
+	assertions.Equal("1.00\t1/1\tpkg\tTestC\n0.50\t1/2\tpkg\tTestA\n", b.String())
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// gateExitCode is the exit code used when the tests were reported but a quality gate failed.
+const gateExitCode = 2
+
+var failOnConditions = []string{"failed", "skipped", "flaky", "race", "slower"}
+
+type (
+	// gates are the quality gates configured with the gate flags.
+	gates struct {
+		failOn       []string
+		minPassRate  float64
+		maxSkipped   int
+		maxDuration  time.Duration
+		requireTests []*regexp.Regexp
+	}
+
+	gateResult struct {
+		Rule    string `json:"rule"`
+		Passed  bool   `json:"passed"`
+		Message string `json:"message"`
+	}
+
+	// gateError is returned when at least one quality gate failed.
+	gateError struct {
+		failed []gateResult
+	}
+)
+
+func (e *gateError) Error() string {
+	messages := make([]string, 0, len(e.failed))
+	for _, result := range e.failed {
+		messages = append(messages, fmt.Sprintf("%s (%s)", result.Rule, result.Message))
+	}
+	return "quality gate failed: " + strings.Join(messages, "; ")
+}
+
+// newGateError returns a *gateError if any of the results failed, and nil otherwise.
+func newGateError(results []gateResult) error {
+	var failed []gateResult
+	for _, result := range results {
+		if !result.Passed {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &gateError{failed: failed}
+}
+
+func parseGateFlags(flags *cmdFlags) (*gates, error) {
+	g := &gates{
+		minPassRate: flags.minPassRateFlag,
+		maxSkipped:  flags.maxSkippedFlag,
+		maxDuration: flags.maxDurationFlag,
+	}
+	for _, condition := range flags.failOnFlag {
+		condition = strings.TrimSpace(condition)
+		valid := false
+		for _, c := range failOnConditions {
+			valid = valid || c == condition
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown --fail-on condition %q; expected one of %s", condition, strings.Join(failOnConditions, ", "))
+		}
+		g.failOn = append(g.failOn, condition)
+	}
+	if g.minPassRate < 0 || g.minPassRate > 100 {
+		return nil, fmt.Errorf("--min-pass-rate must be between 0 and 100, got %g", g.minPassRate)
+	}
+	for _, expr := range flags.requireTestsFlag {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --require-tests expression: %w", err)
+		}
+		g.requireTests = append(g.requireTests, re)
+	}
+	return g, nil
+}
+
+// runDuration returns the time from the first to the last event of the test packages, which run in
+// parallel. Without event times, it is the elapsed time of the slowest package. It is the duration
+// of the report when START_TIME is not provided.
+func runDuration(allPackages map[string]*packageStatus) time.Duration {
+	var first, last time.Time
+	var slowest float64
+	for _, pkg := range allPackages {
+		if !pkg.started.IsZero() && (first.IsZero() || pkg.started.Before(first)) {
+			first = pkg.started
+		}
+		if pkg.finished.After(last) {
+			last = pkg.finished
+		}
+		if pkg.ElapsedTime > slowest {
+			slowest = pkg.ElapsedTime
+		}
+	}
+	if !first.IsZero() {
+		return last.Sub(first).Round(time.Millisecond)
+	}
+	return time.Duration(slowest * float64(time.Second)).Round(time.Millisecond)
+}
+
+// evaluate checks the configured gates against the prepared report. Only configured gates are
+// part of the result.
+func (g *gates) evaluate(tmplData *templateData, allTests map[string]*testStatus, allPackages map[string]*packageStatus) []gateResult {
+	var results []gateResult
+	for _, condition := range g.failOn {
+		var matching []string
+		for _, status := range sortedTests(allTests) {
+			if (condition == "failed" && status.statusText() == "fail" && !status.isKnownFailure()) ||
+				(condition == "skipped" && status.Skipped) ||
+				(condition == "flaky" && status.Flaky) ||
+				(condition == "race" && status.Race) ||
+				(condition == "slower" && status.Slower) {
+				matching = append(matching, status.Package+"."+status.TestName)
+			}
+		}
+		if condition == "failed" {
+			for _, pkg := range tmplData.PackageFailures {
+				matching = append(matching, "package "+pkg.Name)
+			}
+		}
+		if condition == "slower" {
+			for _, pkg := range tmplData.SlowerPackages {
+				matching = append(matching, "package "+pkg.Package)
+			}
+		}
+		result := gateResult{Rule: "fail-on " + condition, Passed: len(matching) == 0, Message: fmt.Sprintf("no %s tests", condition)}
+		if !result.Passed {
+			result.Message = fmt.Sprintf("%d %s: %s", len(matching), condition, abbreviate(matching, 5))
+		}
+		results = append(results, result)
+	}
+	if g.minPassRate > 0 {
+		rate := 100.0
+		if executed := tmplData.NumOfTestPassed + tmplData.NumOfTestFailed; executed > 0 {
+			rate = float64(tmplData.NumOfTestPassed) * 100 / float64(executed)
+		}
+		results = append(results, gateResult{
+			Rule:    fmt.Sprintf("min-pass-rate %g%%", g.minPassRate),
+			Passed:  rate >= g.minPassRate,
+			Message: fmt.Sprintf("pass rate is %.2f%%", rate),
+		})
+	}
+	if g.maxSkipped >= 0 {
+		results = append(results, gateResult{
+			Rule:    fmt.Sprintf("max-skipped %d", g.maxSkipped),
+			Passed:  tmplData.NumOfTestSkipped <= g.maxSkipped,
+			Message: fmt.Sprintf("%d tests skipped", tmplData.NumOfTestSkipped),
+		})
+	}
+	if g.maxDuration > 0 {
+		results = append(results, gateResult{
+			Rule:    fmt.Sprintf("max-duration %s", g.maxDuration),
+			Passed:  tmplData.TestDuration <= g.maxDuration,
+			Message: fmt.Sprintf("tests ran for %s", tmplData.TestDuration),
+		})
+	}
+	for _, re := range g.requireTests {
+		found := false
+		for _, status := range allTests {
+			if re.MatchString(status.TestName) {
+				found = true
+				break
+			}
+		}
+		result := gateResult{Rule: fmt.Sprintf("require-tests %s", re), Passed: found, Message: "a matching test was run"}
+		if !found {
+			result.Message = "no matching test was run"
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// abbreviate joins at most max names and notes how many were left out.
+func abbreviate(names []string, max int) string {
+	if len(names) <= max {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:max], ", "), len(names)-max)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func gatesTestData() (*templateData, map[string]*testStatus, map[string]*packageStatus) {
+	tmplData := &templateData{NumOfTestPassed: 3, NumOfTestFailed: 1, NumOfTestSkipped: 1, NumOfTests: 5, TestDuration: 3500 * time.Millisecond}
+	allTests := map[string]*testStatus{
+		"pkg/a.TestA": {TestName: "TestA", Package: "pkg/a", Passed: true},
+		"pkg/a.TestB": {TestName: "TestB", Package: "pkg/a", Passed: true, Flaky: true},
+		"pkg/a.TestC": {TestName: "TestC", Package: "pkg/a", Race: true},
+		"pkg/b.TestD": {TestName: "TestD", Package: "pkg/b", Passed: true},
+		"pkg/b.TestE": {TestName: "TestE", Package: "pkg/b", Skipped: true},
+	}
+	// the packages ran in parallel from 0s to 3.5s
+	start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	allPackages := map[string]*packageStatus{
+		"pkg/a": {Name: "pkg/a", ElapsedTime: 1.5, started: start, finished: start.Add(1500 * time.Millisecond)},
+		"pkg/b": {Name: "pkg/b", ElapsedTime: 2.5, started: start.Add(time.Second), finished: start.Add(3500 * time.Millisecond)},
+		"pkg/c": {Name: "pkg/c", Failed: true, FailedBuild: "pkg/c [pkg/c.test]"},
+	}
+	return tmplData, allTests, allPackages
+}
+
+func TestGatesEvaluate(t *testing.T) {
+	assertions := assert.New(t)
+	g, err := parseGateFlags(&cmdFlags{
+		failOnFlag:       []string{"failed", "race", "flaky", "skipped"},
+		minPassRateFlag:  80,
+		maxSkippedFlag:   1,
+		maxDurationFlag:  3 * time.Second,
+		requireTestsFlag: []string{"^TestA$", "^TestLogin"},
+	})
+	assertions.Nil(err)
+	tmplData, allTests, allPackages := gatesTestData()
+	tmplData.PackageFailures = packageFailures(allTests, allPackages)
+	results := g.evaluate(tmplData, allTests, allPackages)
+	assertions.Equal([]gateResult{
+		{Rule: "fail-on failed", Passed: false, Message: "2 failed: pkg/a.TestC, package pkg/c"},
+		{Rule: "fail-on race", Passed: false, Message: "1 race: pkg/a.TestC"},
+		{Rule: "fail-on flaky", Passed: false, Message: "1 flaky: pkg/a.TestB"},
+		{Rule: "fail-on skipped", Passed: false, Message: "1 skipped: pkg/b.TestE"},
+		{Rule: "min-pass-rate 80%", Passed: false, Message: "pass rate is 75.00%"},
+		{Rule: "max-skipped 1", Passed: true, Message: "1 tests skipped"},
+		{Rule: "max-duration 3s", Passed: false, Message: "tests ran for 3.5s"},
+		{Rule: "require-tests ^TestA$", Passed: true, Message: "a matching test was run"},
+		{Rule: "require-tests ^TestLogin", Passed: false, Message: "no matching test was run"},
+	}, results)
+
+	err = newGateError(results)
+	assertions.Error(err)
+	assertions.Contains(err.Error(), "quality gate failed: fail-on failed (2 failed: pkg/a.TestC, package pkg/c); fail-on race")
+	assertions.NotContains(err.Error(), "max-skipped")
+}
+
+func TestGatesEvaluateWithoutGates(t *testing.T) {
+	assertions := assert.New(t)
+	g, err := parseGateFlags(&cmdFlags{maxSkippedFlag: -1})
+	assertions.Nil(err)
+	results := g.evaluate(gatesTestData())
+	assertions.Empty(results)
+	assertions.Nil(newGateError(results))
+}
+
+func TestParseGateFlagsIfInvalid(t *testing.T) {
+	assertions := assert.New(t)
+	_, err := parseGateFlags(&cmdFlags{failOnFlag: []string{"broken"}})
+	assertions.EqualError(err, `unknown --fail-on condition "broken"; expected one of failed, skipped, flaky, race, slower`)
+	_, err = parseGateFlags(&cmdFlags{minPassRateFlag: 101})
+	assertions.EqualError(err, `--min-pass-rate must be between 0 and 100, got 101`)
+	_, err = parseGateFlags(&cmdFlags{requireTestsFlag: []string{"("}})
+	assertions.Error(err)
+}
+
+func TestRunDuration(t *testing.T) {
+	assertions := assert.New(t)
+	_, _, allPackages := gatesTestData()
+	assertions.Equal(3500*time.Millisecond, runDuration(allPackages))
+	// without event times, the slowest package is the best guess
+	for _, pkg := range allPackages {
+		pkg.started, pkg.finished = time.Time{}, time.Time{}
+	}
+	assertions.Equal(2500*time.Millisecond, runDuration(allPackages))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	groupByChunk   = "chunk"
+	groupByPackage = "package"
+	groupByFile    = "file"
+	groupByStatus  = "status"
+	groupByOwner   = "owner"
+
+	// ownerAttr is the test attribute, set with t.Attr, that names the owner of a test.
+	ownerAttr = "owner"
+)
+
+var groupBys = []string{groupByChunk, groupByPackage, groupByFile, groupByStatus, groupByOwner}
+
+// statusGroups are the names of the groups of --group-by status, in the order they are shown.
+var statusGroups = []string{"Failed", "Known failures", "Skipped", "Passed"}
+
+func isGroupBy(groupBy string) bool {
+	for _, g := range groupBys {
+		if g == groupBy {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds the test to the group and updates its counts and indicators.
+func (g *testGroupData) add(status *testStatus) {
+	g.TestResults = append(g.TestResults, status)
+	switch {
+	case status.isKnownFailure():
+		g.NumOfKnownFailures++
+	case status.Passed:
+		g.NumOfTestPassed++
+	case status.Skipped:
+		g.NumOfTestSkipped++
+		g.SkippedIndicator = "skipped"
+	default:
+		g.NumOfTestFailed++
+		g.FailureIndicator = "failed"
+	}
+}
+
+// Summary returns the name and the counts of the group, shown as the tooltip of its indicator.
+func (g *testGroupData) Summary() string {
+	counts := []string{fmt.Sprintf("%d passed", g.NumOfTestPassed), fmt.Sprintf("%d failed", g.NumOfTestFailed)}
+	if g.NumOfKnownFailures > 0 {
+		counts = append(counts, fmt.Sprintf("%d known", g.NumOfKnownFailures))
+	}
+	counts = append(counts, fmt.Sprintf("%d skipped", g.NumOfTestSkipped))
+	return fmt.Sprintf("%s: %s", g.Name, strings.Join(counts, ", "))
+}
+
+// groupName returns the name of the group the test belongs to for the group-by modes other than
+// chunk.
+func groupName(status *testStatus, groupBy string) string {
+	switch groupBy {
+	case groupByPackage:
+		return status.Package
+	case groupByFile:
+		if status.TestFileName == "" {
+			return status.Package + " (unknown file)"
+		}
+		return status.Package + "/" + status.TestFileName
+	case groupByStatus:
+		switch {
+		case status.isKnownFailure():
+			return statusGroups[1]
+		case status.Passed:
+			return statusGroups[3]
+		case status.Skipped:
+			return statusGroups[2]
+		}
+		return statusGroups[0]
+	case groupByOwner:
+		if owner := status.Attrs[ownerAttr]; owner != "" {
+			return owner
+		}
+		return "no owner"
+	}
+	return ""
+}
+
+// groupTests arranges the tests into the groups shown as indicators in the report. The chunk mode
+// fills groups of size tests in the order of the tests, the other modes create one group per
+// package, file, status or owner.
+func groupTests(tests []*testStatus, groupBy string, size int) []*testGroupData {
+	var groups []*testGroupData
+	if groupBy == "" || groupBy == groupByChunk {
+		if size < 1 {
+			size = len(tests)
+		}
+		for i, status := range tests {
+			if i%size == 0 {
+				last := i + size
+				if last > len(tests) {
+					last = len(tests)
+				}
+				name := fmt.Sprintf("Tests %d-%d", i+1, last)
+				if last == i+1 {
+					name = fmt.Sprintf("Test %d", last)
+				}
+				groups = append(groups, &testGroupData{Name: name})
+			}
+			groups[len(groups)-1].add(status)
+		}
+		return groups
+	}
+
+	byName := map[string]*testGroupData{}
+	for _, status := range tests {
+		name := groupName(status, groupBy)
+		group, exists := byName[name]
+		if !exists {
+			group = &testGroupData{Name: name}
+			byName[name] = group
+			groups = append(groups, group)
+		}
+		group.add(status)
+	}
+	order := map[string]int{}
+	if groupBy == groupByStatus {
+		for i, name := range statusGroups {
+			order[name] = i
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if order[groups[i].Name] != order[groups[j].Name] {
+			return order[groups[i].Name] < order[groups[j].Name]
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func groupsTestData() []*testStatus {
+	return []*testStatus{
+		{TestName: "TestA", Package: "pkg/a", TestFileName: "a_test.go", Passed: true, Attrs: map[string]string{"owner": "team-x"}},
+		{TestName: "TestB", Package: "pkg/b", TestFileName: "b_test.go"},
+		{TestName: "TestC", Package: "pkg/a", TestFileName: "a_test.go", Skipped: true},
+		{TestName: "TestD", Package: "pkg/b", KnownFailure: &knownFailure{Test: "TestD"}},
+		{TestName: "TestE", Package: "pkg/a", TestFileName: "e_test.go", Passed: true, Attrs: map[string]string{"owner": "team-x"}},
+	}
+}
+
+func TestGroupTests(t *testing.T) {
+	assertions := assert.New(t)
+	names := func(groups []*testGroupData) []string {
+		var names []string
+		for _, group := range groups {
+			names = append(names, group.Name)
+		}
+		return names
+	}
+
+	groups := groupTests(groupsTestData(), groupByChunk, 2)
+	assertions.Equal([]string{"Tests 1-2", "Tests 3-4", "Test 5"}, names(groups))
+	assertions.Equal("failed", groups[0].FailureIndicator)
+	assertions.Equal("skipped", groups[1].SkippedIndicator)
+	assertions.Empty(groups[1].FailureIndicator)
+	assertions.Equal("Tests 3-4: 0 passed, 0 failed, 1 known, 1 skipped", groups[1].Summary())
+
+	groups = groupTests(groupsTestData(), groupByPackage, 2)
+	assertions.Equal([]string{"pkg/a", "pkg/b"}, names(groups))
+	assertions.Len(groups[0].TestResults, 3)
+	assertions.Equal("pkg/a: 2 passed, 0 failed, 1 skipped", groups[0].Summary())
+
+	groups = groupTests(groupsTestData(), groupByFile, 2)
+	assertions.Equal([]string{"pkg/a/a_test.go", "pkg/a/e_test.go", "pkg/b (unknown file)", "pkg/b/b_test.go"}, names(groups))
+
+	groups = groupTests(groupsTestData(), groupByStatus, 2)
+	assertions.Equal([]string{"Failed", "Known failures", "Skipped", "Passed"}, names(groups))
+	assertions.Equal("TestB", groups[0].TestResults[0].TestName)
+
+	groups = groupTests(groupsTestData(), groupByOwner, 2)
+	assertions.Equal([]string{"no owner", "team-x"}, names(groups))
+	assertions.Equal(2, groups[1].NumOfTestPassed)
+
+	assertions.Empty(groupTests(nil, groupByPackage, 2))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	historyFileName    = "history.jsonl"
+	defaultHistoryRuns = 30
+)
+
+type (
+	// historyRecord is one test run in the history file, stored as a single line of JSON.
+	historyRecord struct {
+		Timestamp time.Time         `json:"timestamp"`
+		Commit    string            `json:"commit,omitempty"`
+		Elapsed   float64           `json:"elapsed"`
+		Totals    jsonSummaryCounts `json:"totals"`
+		Tests     []historyTest     `json:"tests"`
+		Packages  []historyPackage  `json:"packages,omitempty"`
+	}
+
+	historyPackage struct {
+		Name    string  `json:"name"`
+		Elapsed float64 `json:"elapsed"`
+	}
+
+	historyTest struct {
+		Package string  `json:"package"`
+		Name    string  `json:"name"`
+		Status  string  `json:"status"`
+		Elapsed float64 `json:"elapsed"`
+		Flaky   bool    `json:"flaky,omitempty"`
+	}
+
+	// historyRun is a run of the history as shown in the trend charts of the HTML report.
+	historyRun struct {
+		Timestamp string
+		Commit    string
+		Elapsed   float64
+		PassRate  float64
+		Passed    int
+		Failed    int
+		Skipped   int
+	}
+)
+
+// newHistoryRecord creates the history record of the current test run.
+func newHistoryRecord(tmplData *templateData, allTests map[string]*testStatus, allPackages map[string]*packageStatus, duration time.Duration) *historyRecord {
+	record := &historyRecord{
+		Timestamp: tmplData.executionTime.UTC().Truncate(time.Second),
+		Commit:    commitID(),
+		Elapsed:   duration.Seconds(),
+		Tests:     []historyTest{},
+	}
+	for _, status := range sortedTests(allTests) {
+		record.Totals.add(status)
+		record.Tests = append(record.Tests, historyTest{
+			Package: status.Package,
+			Name:    status.TestName,
+			Status:  status.statusText(),
+			Elapsed: status.ElapsedTime,
+			Flaky:   status.Flaky,
+		})
+	}
+	for name, pkg := range allPackages {
+		if pkg.Passed {
+			record.Packages = append(record.Packages, historyPackage{Name: name, Elapsed: pkg.ElapsedTime})
+		}
+	}
+	sort.Slice(record.Packages, func(i, j int) bool {
+		return record.Packages[i].Name < record.Packages[j].Name
+	})
+	return record
+}
+
+// commitID returns the commit under test, taken from the CI environment or from git.
+func commitID() string {
+	for _, name := range []string{"GITHUB_SHA", "CI_COMMIT_SHA", "GIT_COMMIT"} {
+		if commit := os.Getenv(name); commit != "" {
+			return commit
+		}
+	}
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// readHistory returns the last max records of the history file. A missing file is an empty history.
+func readHistory(filename string, max int) ([]*historyRecord, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []*historyRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 256*1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		record := &historyRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return nil, fmt.Errorf("invalid history record in %s:%d: %w", filename, lineNum, err)
+		}
+		records = append(records, record)
+		if len(records) > max {
+			records = records[1:]
+		}
+	}
+	return records, scanner.Err()
+}
+
+// appendHistory appends the record to the history file.
+func appendHistory(filename string, record *historyRecord) error {
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(content, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// updateHistory appends the current run to the history in dir and adds the trends and the flaky
+// tests of the last runs, including the current one, to the report.
+func updateHistory(dir string, runs int, record *historyRecord, tmplData *templateData, allTests map[string]*testStatus) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	filename := filepath.Join(dir, historyFileName)
+	records, err := readHistory(filename, runs)
+	if err != nil {
+		return err
+	}
+	if err := appendHistory(filename, record); err != nil {
+		return err
+	}
+	records = append(records, record)
+	if len(records) > runs {
+		records = records[len(records)-runs:]
+	}
+	addHistory(records, tmplData, allTests)
+	tmplData.FlakyTests = flakinessRanking(records)
+	if len(tmplData.FlakyTests) > flakyPanelSize {
+		tmplData.FlakyTests = tmplData.FlakyTests[:flakyPanelSize]
+	}
+	return nil
+}
+
+// addHistory sets the trend chart data of the report and the recent outcomes of every test,
+// oldest first. Runs in which a test did not exist have an empty outcome.
+func addHistory(records []*historyRecord, tmplData *templateData, allTests map[string]*testStatus) {
+	tmplData.History = make([]historyRun, 0, len(records))
+	for i, record := range records {
+		run := historyRun{
+			Timestamp: record.Timestamp.Format(time.RFC3339),
+			Commit:    record.Commit,
+			Elapsed:   record.Elapsed,
+			PassRate:  100,
+			Passed:    record.Totals.Passed,
+			Failed:    record.Totals.Failed,
+			Skipped:   record.Totals.Skipped,
+		}
+		if executed := record.Totals.Passed + record.Totals.Failed; executed > 0 {
+			run.PassRate = float64(record.Totals.Passed) * 100 / float64(executed)
+		}
+		tmplData.History = append(tmplData.History, run)
+		for _, test := range record.Tests {
+			status, exists := allTests[test.Package+"."+test.Name]
+			if !exists {
+				continue
+			}
+			if status.History == nil {
+				status.History = make([]string, len(records))
+			}
+			status.History[i] = test.Status
+		}
+	}
+}
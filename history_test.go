@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateHistory(t *testing.T) {
+	assertions := assert.New(t)
+	t.Setenv("GITHUB_SHA", "0123456789abcdef")
+	dir := filepath.Join(t.TempDir(), "history")
+	for i, outcome := range []bool{true, false, true} {
+		allTests := map[string]*testStatus{
+			"pkg.TestA": {TestName: "TestA", Package: "pkg", Passed: outcome, ElapsedTime: float64(i)},
+		}
+		if i == 2 {
+			allTests["pkg.TestB"] = &testStatus{TestName: "TestB", Package: "pkg", Skipped: true}
+		}
+		tmplData := &templateData{executionTime: time.Date(2024, 5, 1, 12, i, 0, 0, time.UTC)}
+		record := newHistoryRecord(tmplData, allTests, nil, time.Duration(i+1)*time.Second)
+		assertions.Nil(updateHistory(dir, 2, record, tmplData, allTests))
+
+		if i == 2 {
+			assertions.Equal([]historyRun{
+				{Timestamp: "2024-05-01T12:01:00Z", Commit: "0123456789abcdef", Elapsed: 2, PassRate: 0, Failed: 1},
+				{Timestamp: "2024-05-01T12:02:00Z", Commit: "0123456789abcdef", Elapsed: 3, PassRate: 100, Passed: 1, Skipped: 1},
+			}, tmplData.History)
+			assertions.Equal([]string{"fail", "pass"}, allTests["pkg.TestA"].History)
+			assertions.Equal([]string{"", "skip"}, allTests["pkg.TestB"].History)
+		}
+	}
+	records, err := readHistory(filepath.Join(dir, historyFileName), 10)
+	assertions.Nil(err)
+	assertions.Len(records, 3)
+	assertions.Equal(historyTest{Package: "pkg", Name: "TestA", Status: "pass", Elapsed: 0}, records[0].Tests[0])
+}
+
+func TestReadHistoryIfInvalid(t *testing.T) {
+	assertions := assert.New(t)
+	filename := filepath.Join(t.TempDir(), historyFileName)
+	assertions.Nil(os.WriteFile(filename, []byte("{\"totals\":{}}\n{invalid\n"), 0644))
+	_, err := readHistory(filename, 10)
+	assertions.EqualError(err, "invalid history record in "+filename+":2: invalid character 'i' looking for beginning of object key string")
+
+	records, err := readHistory(filepath.Join(t.TempDir(), "missing.jsonl"), 10)
+	assertions.Nil(err)
+	assertions.Empty(records)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// jsonSummarySchemaVersion is bumped whenever a field of the JSON summary is renamed, removed or
+// changes meaning. Adding new fields does not change the version.
+const jsonSummarySchemaVersion = 1
+
+type (
+	jsonSummary struct {
+		SchemaVersion int                   `json:"schemaVersion"`
+		Title         string                `json:"title"`
+		GeneratedAt   time.Time             `json:"generatedAt"`
+		Elapsed       float64               `json:"elapsed"`
+		Totals        jsonSummaryCounts     `json:"totals"`
+		Packages      []*jsonSummaryPackage `json:"packages"`
+		Tests         []*jsonSummaryTest    `json:"tests"`
+		Gates         []gateResult          `json:"gates,omitempty"`
+		Redactions    int                   `json:"redactions,omitempty"`
+		RunMetadata   []Info                `json:"runMetadata,omitempty"`
+	}
+
+	jsonSummaryCounts struct {
+		Total   int `json:"total"`
+		Passed  int `json:"passed"`
+		Failed  int `json:"failed"`
+		Skipped int `json:"skipped"`
+		Known   int `json:"known"`
+	}
+
+	jsonSummaryPackage struct {
+		Name            string            `json:"name"`
+		Elapsed         float64           `json:"elapsed"`
+		BaselineElapsed float64           `json:"baselineElapsed,omitempty"`
+		Slower          bool              `json:"slower,omitempty"`
+		Counts          jsonSummaryCounts `json:"counts"`
+		Failed          bool              `json:"failed,omitempty"`
+		FailedBuild     string            `json:"failedBuild,omitempty"`
+		Output          string            `json:"output,omitempty"`
+	}
+
+	jsonSummaryLocation struct {
+		File string `json:"file"`
+		Path string `json:"path,omitempty"`
+		Line int    `json:"line"`
+		Col  int    `json:"col"`
+	}
+
+	jsonSummaryTest struct {
+		ID              string               `json:"id"`
+		Name            string               `json:"name"`
+		Package         string               `json:"package"`
+		Status          string               `json:"status"`
+		Elapsed         float64              `json:"elapsed"`
+		Location        *jsonSummaryLocation `json:"location,omitempty"`
+		Failures        []failureMessage     `json:"failures,omitempty"`
+		SkipReason      string               `json:"skipReason,omitempty"`
+		Screenshots     []string             `json:"screenshots,omitempty"`
+		Metadata        map[string]string    `json:"metadata,omitempty"`
+		Flaky           bool                 `json:"flaky,omitempty"`
+		Race            bool                 `json:"race,omitempty"`
+		KnownFailure    *knownFailure        `json:"knownFailure,omitempty"`
+		BaselineElapsed float64              `json:"baselineElapsed,omitempty"`
+		Slower          bool                 `json:"slower,omitempty"`
+		TruncatedBytes  int                  `json:"truncatedBytes,omitempty"`
+	}
+)
+
+func (c *jsonSummaryCounts) add(status *testStatus) {
+	c.Total++
+	switch status.statusText() {
+	case "pass":
+		c.Passed++
+	case "skip":
+		c.Skipped++
+	default:
+		if status.isKnownFailure() {
+			c.Known++
+		} else {
+			c.Failed++
+		}
+	}
+}
+
+// isKnownFailure reports whether the test failed and is listed in the known failures file.
+func (t *jsonSummaryTest) isKnownFailure() bool {
+	return t.Status == "fail" && t.KnownFailure != nil
+}
+
+// newJSONSummary builds the JSON summary from the tests and packages of a generated report.
+func newJSONSummary(tmplData *templateData, allTests map[string]*testStatus, allPackages map[string]*packageStatus) *jsonSummary {
+	summary := &jsonSummary{
+		SchemaVersion: jsonSummarySchemaVersion,
+		Title:         tmplData.ReportTitle,
+		GeneratedAt:   tmplData.executionTime,
+		Elapsed:       tmplData.TestDuration.Seconds(),
+		Packages:      []*jsonSummaryPackage{},
+		Tests:         []*jsonSummaryTest{},
+		Gates:         tmplData.GateResults,
+		Redactions:    tmplData.Redactions,
+		RunMetadata:   tmplData.ServerInfo,
+	}
+	packages := map[string]*jsonSummaryPackage{}
+	for _, status := range sortedTests(allTests) {
+		test := &jsonSummaryTest{
+			ID:              testID(status.Package, status.TestName),
+			Name:            status.TestName,
+			Package:         status.Package,
+			Status:          status.statusText(),
+			Elapsed:         status.ElapsedTime,
+			Screenshots:     status.Screenshots,
+			Metadata:        status.Attrs,
+			Flaky:           status.Flaky,
+			Race:            status.Race,
+			KnownFailure:    status.KnownFailure,
+			BaselineElapsed: status.BaselineElapsed,
+			Slower:          status.Slower,
+			TruncatedBytes:  status.TruncatedBytes,
+		}
+		if status.TestFileName != "" {
+			test.Location = &jsonSummaryLocation{
+				File: status.TestFileName,
+				Path: relativePath(status.testFilePath),
+				Line: status.TestFunctionDetail.Line,
+				Col:  status.TestFunctionDetail.Col,
+			}
+		}
+		switch test.Status {
+		case "fail":
+			test.Failures = parseFailureMessages(status.Output)
+		case "skip":
+			if messages := parseFailureMessages(status.Output); len(messages) > 0 {
+				test.SkipReason = messages[len(messages)-1].Message
+			}
+		}
+		summary.Tests = append(summary.Tests, test)
+		summary.Totals.add(status)
+
+		pkg, exists := packages[status.Package]
+		if !exists {
+			pkg = newJSONSummaryPackage(status.Package, allPackages[status.Package])
+			packages[status.Package] = pkg
+			summary.Packages = append(summary.Packages, pkg)
+		}
+		pkg.Counts.add(status)
+	}
+	// packages that failed without running tests, e.g. because they do not build
+	for name, pkgStatus := range allPackages {
+		if _, exists := packages[name]; !exists && pkgStatus.Failed {
+			summary.Packages = append(summary.Packages, newJSONSummaryPackage(name, pkgStatus))
+		}
+	}
+	sort.Slice(summary.Packages, func(i, j int) bool {
+		return summary.Packages[i].Name < summary.Packages[j].Name
+	})
+	return summary
+}
+
+func newJSONSummaryPackage(name string, pkgStatus *packageStatus) *jsonSummaryPackage {
+	pkg := &jsonSummaryPackage{Name: name}
+	if pkgStatus != nil {
+		pkg.Elapsed = pkgStatus.ElapsedTime
+		pkg.BaselineElapsed = pkgStatus.BaselineElapsed
+		pkg.Slower = pkgStatus.Slower
+		pkg.Failed = pkgStatus.Failed
+		if pkg.Failed {
+			pkg.FailedBuild = pkgStatus.FailedBuild
+			pkg.Output = strings.Join(pkgStatus.Output, "")
+		}
+	}
+	return pkg
+}
+
+// stripColors removes the ANSI escape sequences from the failure messages and skip reasons of the
+// tests and from the output of the failed packages, which every text output is rendered from.
+func (s *jsonSummary) stripColors() {
+	for _, test := range s.Tests {
+		for i := range test.Failures {
+			test.Failures[i].Message = stripColors(test.Failures[i].Message)
+		}
+		test.SkipReason = stripColors(test.SkipReason)
+	}
+	for _, pkg := range s.Packages {
+		pkg.Output = stripColors(pkg.Output)
+	}
+}
+
+// relativePath returns the path relative to the working directory, which is the repository root
+// when the report is generated in CI. Paths outside of the working directory are kept as is.
+func relativePath(path string) string {
+	if path == "" || !filepath.IsAbs(path) {
+		return filepath.ToSlash(path)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// failedPackages returns the packages that failed outside of their tests, e.g. because they do not
+// build or TestMain failed, which the formats without packages report as failed tests.
+func (s *jsonSummary) failedPackages() []*jsonSummaryPackage {
+	var failed []*jsonSummaryPackage
+	for _, pkg := range s.Packages {
+		if pkg.Failed && pkg.Counts.Failed == 0 && pkg.Counts.Known == 0 {
+			failed = append(failed, pkg)
+		}
+	}
+	return failed
+}
+
+// failureText describes the failure of a package that failed outside of its tests.
+func (p *jsonSummaryPackage) failureText() string {
+	if p.FailedBuild != "" {
+		return "build failed: " + p.FailedBuild
+	}
+	return "package failed outside of its tests"
+}
+
+// hasFailedSubtest reports whether a subtest of the test failed, which is the usual reason for a
+// parent test to fail without a failure message of its own.
+func (s *jsonSummary) hasFailedSubtest(test *jsonSummaryTest) bool {
+	prefix := test.Name + "/"
+	for _, t := range s.Tests {
+		if t.Status == "fail" && t.Package == test.Package && strings.HasPrefix(t.Name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSONSummary(w io.Writer, summary *jsonSummary) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(summary)
+}
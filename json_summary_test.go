@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJSONSummary(t *testing.T) {
+	assertions := assert.New(t)
+	tmplData := &templateData{
+		ReportTitle:  "test-title",
+		TestDuration: 1500 * time.Millisecond,
+	}
+	allTests := map[string]*testStatus{
+		"pkg/b.TestB": {
+			TestName:    "TestB",
+			Package:     "pkg/b",
+			ElapsedTime: 0.5,
+			Passed:      true,
+		},
+		"pkg/a.TestA": {
+			TestName:           "TestA",
+			Package:            "pkg/a",
+			ElapsedTime:        0.25,
+			Output:             []string{"    a_test.go:3: boom\n"},
+			TestFileName:       "a_test.go",
+			TestFunctionDetail: testFunctionFilePos{Line: 2, Col: 1},
+			Attrs:              map[string]string{"owner": "team-x"},
+		},
+		"pkg/a.TestC": {
+			TestName: "TestC",
+			Package:  "pkg/a",
+			Skipped:  true,
+		},
+	}
+	allPackages := map[string]*packageStatus{
+		"pkg/a": {Name: "pkg/a", ElapsedTime: 0.3},
+		"pkg/b": {Name: "pkg/b", ElapsedTime: 0.6, Passed: true},
+	}
+	summary := newJSONSummary(tmplData, allTests, allPackages)
+	assertions.Equal(jsonSummarySchemaVersion, summary.SchemaVersion)
+	assertions.Equal(1.5, summary.Elapsed)
+	assertions.Equal(jsonSummaryCounts{Total: 3, Passed: 1, Failed: 1, Skipped: 1}, summary.Totals)
+
+	assertions.Len(summary.Packages, 2)
+	assertions.Equal("pkg/a", summary.Packages[0].Name)
+	assertions.Equal(0.3, summary.Packages[0].Elapsed)
+	assertions.Equal(jsonSummaryCounts{Total: 2, Failed: 1, Skipped: 1}, summary.Packages[0].Counts)
+
+	assertions.Len(summary.Tests, 3)
+	failed := summary.Tests[0]
+	assertions.Equal("TestA", failed.Name)
+	assertions.Equal(testID("pkg/a", "TestA"), failed.ID)
+	assertions.Equal("fail", failed.Status)
+	assertions.Equal(&jsonSummaryLocation{File: "a_test.go", Line: 2, Col: 1}, failed.Location)
+	assertions.Equal([]failureMessage{{File: "a_test.go", Line: 3, Message: "boom"}}, failed.Failures)
+	assertions.Equal("team-x", failed.Metadata["owner"])
+	assertions.Equal("skip", summary.Tests[1].Status)
+	assertions.Nil(summary.Tests[1].Location)
+	assertions.Equal("pass", summary.Tests[2].Status)
+
+	buffer := &bytes.Buffer{}
+	assertions.Nil(writeJSONSummary(buffer, summary))
+	decoded := map[string]interface{}{}
+	assertions.Nil(json.Unmarshal(buffer.Bytes(), &decoded))
+	assertions.Equal(float64(jsonSummarySchemaVersion), decoded["schemaVersion"])
+	assertions.Contains(decoded, "totals")
+}
+
+func TestJSONSummaryElapsedFromEventTimes(t *testing.T) {
+	assertions := assert.New(t)
+	t.Setenv("START_TIME", "")
+	summaryFile := filepath.Join(t.TempDir(), "summary.json")
+	rootCmd, tmplData, _ := initRootCommand()
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetArgs(reportArgs(t, "--json-summary", summaryFile))
+	assertions.Nil(rootCmd.Execute())
+	content, err := os.ReadFile(summaryFile)
+	assertions.Nil(err)
+	var summary jsonSummary
+	assertions.Nil(json.Unmarshal(content, &summary))
+	// testdata/simple.json spans 10ms, however long the report takes to parse it
+	assertions.Equal(0.01, summary.Elapsed)
+	assertions.Equal(10*time.Millisecond, tmplData.TestDuration)
+}
+
+func TestJSONSummaryStripColors(t *testing.T) {
+	assertions := assert.New(t)
+	allTests := map[string]*testStatus{
+		"pkg/a.TestA": {TestName: "TestA", Package: "pkg/a", Output: []string{"    a_test.go:3: \x1b[31mboom\x1b[0m\n"}},
+		"pkg/a.TestB": {TestName: "TestB", Package: "pkg/a", Skipped: true, Output: []string{"    a_test.go:9: \x1b[33mlater\x1b[0m\n"}},
+	}
+	allPackages := map[string]*packageStatus{
+		"pkg/a": {Name: "pkg/a"},
+		"pkg/b": {Name: "pkg/b", Failed: true, Output: []string{"\x1b[1mTestMain\x1b[0m: setup failed\n"}},
+	}
+	summary := newJSONSummary(&templateData{}, allTests, allPackages)
+	assertions.Equal("\x1b[31mboom\x1b[0m", summary.Tests[0].Failures[0].Message)
+	assertions.Equal("\x1b[1mTestMain\x1b[0m: setup failed\n", summary.Packages[1].Output)
+	summary.stripColors()
+	assertions.Equal("boom", summary.Tests[0].Failures[0].Message)
+	assertions.Equal("later", summary.Tests[1].SkipReason)
+	assertions.Equal("TestMain: setup failed\n", summary.Packages[1].Output)
+}
+
+// buildFailureSummary returns the JSON summary of a run with a package that does not build, one
+// that passes and one whose TestMain fails.
+func buildFailureSummary(t *testing.T) *jsonSummary {
+	allPackages, allTests, err := readTestDataFromFile(filepath.Join("testdata", "build_failure.json"), &cmdFlags{}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newJSONSummary(&templateData{}, allTests, allPackages)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type (
+	junitTestSuites struct {
+		XMLName  xml.Name          `xml:"testsuites"`
+		Name     string            `xml:"name,attr,omitempty"`
+		Tests    int               `xml:"tests,attr"`
+		Failures int               `xml:"failures,attr"`
+		Errors   int               `xml:"errors,attr,omitempty"`
+		Skipped  int               `xml:"skipped,attr"`
+		Time     string            `xml:"time,attr"`
+		Suites   []*junitTestSuite `xml:"testsuite"`
+	}
+
+	junitTestSuite struct {
+		Name      string           `xml:"name,attr"`
+		Tests     int              `xml:"tests,attr"`
+		Failures  int              `xml:"failures,attr"`
+		Errors    int              `xml:"errors,attr,omitempty"`
+		Skipped   int              `xml:"skipped,attr"`
+		Time      string           `xml:"time,attr"`
+		Timestamp string           `xml:"timestamp,attr,omitempty"`
+		TestCases []*junitTestCase `xml:"testcase"`
+	}
+
+	junitTestCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Time      string        `xml:"time,attr"`
+		File      string        `xml:"file,attr,omitempty"`
+		Line      int           `xml:"line,attr,omitempty"`
+		Failure   *junitMessage `xml:"failure,omitempty"`
+		Error     *junitMessage `xml:"error,omitempty"`
+		Skipped   *junitMessage `xml:"skipped,omitempty"`
+	}
+
+	junitMessage struct {
+		Message string `xml:"message,attr,omitempty"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+func junitTime(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
+
+// writeJUnit writes the summary as JUnit XML with one test suite per package. A package that failed
+// outside of its tests has a test case named after the package with an error and its output.
+func writeJUnit(w io.Writer, summary *jsonSummary) error {
+	suites := &junitTestSuites{
+		Name:     summary.Title,
+		Tests:    summary.Totals.Total,
+		Failures: summary.Totals.Failed,
+		Skipped:  summary.Totals.Skipped + summary.Totals.Known,
+		Time:     junitTime(summary.Elapsed),
+	}
+	timestamp := ""
+	if !summary.GeneratedAt.IsZero() {
+		timestamp = summary.GeneratedAt.Format("2006-01-02T15:04:05")
+	}
+	suitesByName := map[string]*junitTestSuite{}
+	for _, pkg := range summary.Packages {
+		suite := &junitTestSuite{
+			Name:      pkg.Name,
+			Tests:     pkg.Counts.Total,
+			Failures:  pkg.Counts.Failed,
+			Skipped:   pkg.Counts.Skipped + pkg.Counts.Known,
+			Time:      junitTime(pkg.Elapsed),
+			Timestamp: timestamp,
+		}
+		suitesByName[pkg.Name] = suite
+		suites.Suites = append(suites.Suites, suite)
+	}
+	for _, test := range summary.Tests {
+		suite := suitesByName[test.Package]
+		if suite == nil {
+			continue
+		}
+		testCase := &junitTestCase{
+			Name:      test.Name,
+			ClassName: test.Package,
+			Time:      junitTime(test.Elapsed),
+		}
+		if test.Location != nil {
+			testCase.File = test.Location.Path
+			testCase.Line = test.Location.Line
+		}
+		switch {
+		case test.isKnownFailure():
+			// known failures are reported as skipped so they do not fail the build
+			testCase.Skipped = &junitMessage{Message: knownFailureText(test.KnownFailure)}
+		case test.Status == "fail":
+			failure := &junitMessage{Message: "Failed"}
+			var lines []string
+			for _, message := range test.Failures {
+				lines = append(lines, fmt.Sprintf("%s:%d: %s", message.File, message.Line, message.Message))
+			}
+			if len(test.Failures) > 0 {
+				failure.Message = test.Failures[0].Message
+			}
+			failure.Text = strings.Join(lines, "\n")
+			testCase.Failure = failure
+		case test.Status == "skip":
+			testCase.Skipped = &junitMessage{Message: test.SkipReason}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	for _, pkg := range summary.failedPackages() {
+		suite := suitesByName[pkg.Name]
+		suite.TestCases = append(suite.TestCases, &junitTestCase{
+			Name:      pkg.Name,
+			ClassName: pkg.Name,
+			Time:      junitTime(pkg.Elapsed),
+			Error:     &junitMessage{Message: pkg.failureText(), Text: pkg.Output},
+		})
+		suite.Tests++
+		suite.Errors++
+		suites.Tests++
+		suites.Errors++
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	assertions := assert.New(t)
+	summary := &jsonSummary{
+		Title:   "test-title",
+		Elapsed: 1.5,
+		Totals:  jsonSummaryCounts{Total: 3, Passed: 1, Failed: 1, Skipped: 1},
+		Packages: []*jsonSummaryPackage{
+			{Name: "pkg/a", Elapsed: 1.25, Counts: jsonSummaryCounts{Total: 3, Passed: 1, Failed: 1, Skipped: 1}},
+		},
+		Tests: []*jsonSummaryTest{
+			{Name: "TestA", Package: "pkg/a", Status: "fail", Elapsed: 0.5,
+				Location: &jsonSummaryLocation{File: "a_test.go", Path: "pkg/a/a_test.go", Line: 10},
+				Failures: []failureMessage{{File: "a_test.go", Line: 12, Message: "want <1>"}}},
+			{Name: "TestB", Package: "pkg/a", Status: "pass", Elapsed: 0.25},
+			{Name: "TestC", Package: "pkg/a", Status: "skip", SkipReason: "needs docker"},
+		},
+	}
+	buffer := &bytes.Buffer{}
+	assertions.Nil(writeJUnit(buffer, summary))
+	assertions.Contains(buffer.String(), `<testsuites name="test-title" tests="3" failures="1" skipped="1" time="1.500">`)
+	assertions.Contains(buffer.String(), `<failure message="want &lt;1&gt;">a_test.go:12: want &lt;1&gt;</failure>`)
+
+	decoded := &junitTestSuites{}
+	assertions.Nil(xml.Unmarshal(buffer.Bytes(), decoded))
+	assertions.Len(decoded.Suites, 1)
+	assertions.Equal("1.250", decoded.Suites[0].Time)
+	assertions.Len(decoded.Suites[0].TestCases, 3)
+	assertions.Equal("pkg/a/a_test.go", decoded.Suites[0].TestCases[0].File)
+	assertions.Equal(10, decoded.Suites[0].TestCases[0].Line)
+	assertions.Nil(decoded.Suites[0].TestCases[1].Failure)
+	assertions.Equal("needs docker", decoded.Suites[0].TestCases[2].Skipped.Message)
+}
+
+func TestWriteJUnitPackageFailures(t *testing.T) {
+	assertions := assert.New(t)
+	buffer := &bytes.Buffer{}
+	assertions.Nil(writeJUnit(buffer, buildFailureSummary(t)))
+	assertions.Contains(buffer.String(), `<testsuites tests="3" failures="0" errors="2" skipped="0" time="0.000">`)
+
+	decoded := &junitTestSuites{}
+	assertions.Nil(xml.Unmarshal(buffer.Bytes(), decoded))
+	assertions.Len(decoded.Suites, 3)
+	broken := decoded.Suites[0]
+	assertions.Equal("example.com/bf/broken", broken.Name)
+	assertions.Equal(1, broken.Tests)
+	assertions.Equal(1, broken.Errors)
+	assertions.Len(broken.TestCases, 1)
+	assertions.Equal("example.com/bf/broken", broken.TestCases[0].Name)
+	assertions.Equal("build failed: example.com/bf/broken [example.com/bf/broken.test]", broken.TestCases[0].Error.Message)
+	assertions.Contains(broken.TestCases[0].Error.Text, "broken/broken_test.go:5:33: undefined: undefinedCall\n")
+	assertions.Equal(0, decoded.Suites[1].Errors)
+	assertions.Nil(decoded.Suites[1].TestCases[0].Error)
+	mainFail := decoded.Suites[2]
+	assertions.Equal(1, mainFail.Errors)
+	assertions.Equal("package failed outside of its tests", mainFail.TestCases[0].Error.Message)
+	assertions.Contains(mainFail.TestCases[0].Error.Text, "setup failed\n")
+}
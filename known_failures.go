@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// knownFailure is an entry of the known failures file: tests that are known to be broken, e.g.
+// because the failure is tracked in a ticket.
+type knownFailure struct {
+	Package string `yaml:"package" json:"package,omitempty"`
+	Test    string `yaml:"test" json:"test"`
+	Reason  string `yaml:"reason" json:"reason,omitempty"`
+	Ticket  string `yaml:"ticket" json:"ticket,omitempty"`
+
+	packageRegExp *regexp.Regexp
+	testRegExp    *regexp.Regexp
+}
+
+// readKnownFailures reads a known failures file. The file is a YAML or JSON list of entries, each
+// matching the tests whose whole package and name match its package and test regular expressions.
+// An empty package matches all packages.
+func readKnownFailures(filename string) ([]*knownFailure, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var knownFailures []*knownFailure
+	if err := yaml.Unmarshal(content, &knownFailures); err != nil {
+		return nil, fmt.Errorf("invalid known failures file %s: %w", filename, err)
+	}
+	for i, kf := range knownFailures {
+		if kf.Test == "" {
+			return nil, fmt.Errorf("invalid known failures file %s: entry %d has no test", filename, i+1)
+		}
+		if err := kf.compile(); err != nil {
+			return nil, fmt.Errorf("invalid known failures file %s: entry %d: %w", filename, i+1, err)
+		}
+	}
+	return knownFailures, nil
+}
+
+// compile compiles the package and test patterns anchored at both ends, so TestFoo does not match
+// TestFooBar or the subtests of TestFoo.
+func (kf *knownFailure) compile() (err error) {
+	packagePattern := kf.Package
+	if packagePattern == "" {
+		packagePattern = ".*"
+	}
+	if kf.packageRegExp, err = regexp.Compile("^(?:" + packagePattern + ")$"); err != nil {
+		return err
+	}
+	kf.testRegExp, err = regexp.Compile("^(?:" + kf.Test + ")$")
+	return err
+}
+
+// knownFailureText describes the known failure as "known failure: reason (ticket)".
+func knownFailureText(kf *knownFailure) string {
+	text := "known failure"
+	if kf.Reason != "" {
+		text += ": " + kf.Reason
+	}
+	if kf.Ticket != "" {
+		text += " (" + kf.Ticket + ")"
+	}
+	return text
+}
+
+func (kf *knownFailure) matches(status *testStatus) bool {
+	return kf.packageRegExp.MatchString(status.Package) && kf.testRegExp.MatchString(status.TestName)
+}
+
+// isKnownFailure reports whether the test failed and is listed in the known failures file.
+func (s *testStatus) isKnownFailure() bool {
+	return s.KnownFailure != nil && s.statusText() == "fail"
+}
+
+// isFixedKnownFailure reports whether the test is listed in the known failures file but passed,
+// so it can be removed from the file.
+func (s *testStatus) isFixedKnownFailure() bool {
+	return s.KnownFailure != nil && s.statusText() == "pass"
+}
+
+// classifyKnownFailures sets the known failure of every test matching an entry of the known
+// failures file. A failed test that does not match an entry itself, but failed only because all
+// of its failed subtests are known failures, gets the known failure of its first failed subtest.
+func classifyKnownFailures(allTests map[string]*testStatus, knownFailures []*knownFailure) {
+	if len(knownFailures) == 0 {
+		return
+	}
+	tests := sortedTests(allTests)
+	failedSubtests := map[string][]*testStatus{}
+	for _, status := range tests {
+		if i := strings.LastIndex(status.TestName, "/"); i >= 0 && status.statusText() == "fail" {
+			parent := status.Package + "." + status.TestName[:i]
+			failedSubtests[parent] = append(failedSubtests[parent], status)
+		}
+	}
+	// subtests are classified before their parents
+	sort.SliceStable(tests, func(i, j int) bool {
+		return strings.Count(tests[i].TestName, "/") > strings.Count(tests[j].TestName, "/")
+	})
+	for _, status := range tests {
+		for _, kf := range knownFailures {
+			if kf.matches(status) {
+				status.KnownFailure = kf
+				break
+			}
+		}
+		subtests := failedSubtests[status.Package+"."+status.TestName]
+		if status.KnownFailure != nil || status.statusText() != "fail" || len(subtests) == 0 || len(parseFailureMessages(status.Output)) > 0 {
+			continue
+		}
+		known := true
+		for _, subtest := range subtests {
+			known = known && subtest.isKnownFailure()
+		}
+		if known {
+			status.KnownFailure = subtests[0].KnownFailure
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadKnownFailures(t *testing.T) {
+	assertions := assert.New(t)
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "known.yaml")
+	assertions.Nil(os.WriteFile(filename, []byte(`
+- package: ^example.com/pkg$
+  test: ^TestUpload
+  reason: flaky storage
+  ticket: https://example.com/issues/1
+`), 0644))
+	knownFailures, err := readKnownFailures(filename)
+	assertions.Nil(err)
+	assertions.Len(knownFailures, 1)
+	assertions.Equal("flaky storage", knownFailures[0].Reason)
+	assertions.Equal("known failure: flaky storage (https://example.com/issues/1)", knownFailureText(knownFailures[0]))
+
+	filename = filepath.Join(dir, "known.json")
+	assertions.Nil(os.WriteFile(filename, []byte(`[{"test": "TestA"}, {"package": "pkg"}]`), 0644))
+	_, err = readKnownFailures(filename)
+	assertions.EqualError(err, "invalid known failures file "+filename+": entry 2 has no test")
+}
+
+func TestClassifyKnownFailures(t *testing.T) {
+	assertions := assert.New(t)
+	knownFailures := []*knownFailure{{Test: "^TestA/one$", Reason: "broken"}, {Package: "other", Test: "TestB"}, {Test: "^TestC$"}}
+	for _, kf := range knownFailures {
+		assertions.Nil(kf.compile())
+	}
+	allTests := map[string]*testStatus{
+		"pkg.TestA":     {TestName: "TestA", Package: "pkg"},
+		"pkg.TestA/one": {TestName: "TestA/one", Package: "pkg"},
+		"pkg.TestA/two": {TestName: "TestA/two", Package: "pkg", Passed: true},
+		"pkg.TestB":     {TestName: "TestB", Package: "pkg"},
+		"pkg.TestC":     {TestName: "TestC", Package: "pkg", Passed: true},
+		"pkg.TestD":     {TestName: "TestD", Package: "pkg"},
+		"pkg.TestD/one": {TestName: "TestD/one", Package: "pkg"},
+	}
+	allTests["pkg.TestD/one"].Output = []string{"    d_test.go:5: boom\n"}
+	allTests["pkg.TestD"].Output = []string{"    d_test.go:9: own failure\n"}
+	classifyKnownFailures(allTests, knownFailures)
+
+	assertions.True(allTests["pkg.TestA/one"].isKnownFailure())
+	// the parent failed only because of its known failing subtest
+	assertions.True(allTests["pkg.TestA"].isKnownFailure())
+	assertions.Nil(allTests["pkg.TestA/two"].KnownFailure)
+	// the package does not match
+	assertions.False(allTests["pkg.TestB"].isKnownFailure())
+	assertions.True(allTests["pkg.TestC"].isFixedKnownFailure())
+	assertions.False(allTests["pkg.TestD"].isKnownFailure())
+
+	tmplData := &templateData{knownFailures: knownFailures}
+	prepareReport(tmplData, allTests, testFileDetailsByPackage{}, 0)
+	assertions.Equal(3, tmplData.NumOfTestFailed)
+	assertions.Equal(2, tmplData.NumOfKnownFailures)
+	assertions.Equal([]*testStatus{allTests["pkg.TestC"]}, tmplData.FixedKnownFailures)
+}
+
+func TestKnownFailureMatchesWholeName(t *testing.T) {
+	assertions := assert.New(t)
+	kf := &knownFailure{Package: "example.com/pkg", Test: "TestFoo"}
+	assertions.Nil(kf.compile())
+	assertions.True(kf.matches(&testStatus{TestName: "TestFoo", Package: "example.com/pkg"}))
+	assertions.False(kf.matches(&testStatus{TestName: "TestFooBar", Package: "example.com/pkg"}))
+	assertions.False(kf.matches(&testStatus{TestName: "TestFoo/one", Package: "example.com/pkg"}))
+	assertions.False(kf.matches(&testStatus{TestName: "TestFoo", Package: "example.com/pkg/sub"}))
+	// alternatives are anchored as a whole
+	kf = &knownFailure{Test: "TestFoo/.*|TestBar"}
+	assertions.Nil(kf.compile())
+	assertions.True(kf.matches(&testStatus{TestName: "TestFoo/one", Package: "example.com/pkg"}))
+	assertions.True(kf.matches(&testStatus{TestName: "TestBar", Package: "example.com/other"}))
+	assertions.False(kf.matches(&testStatus{TestName: "TestFoo", Package: "example.com/pkg"}))
+	assertions.False(kf.matches(&testStatus{TestName: "TestBarBaz", Package: "example.com/pkg"}))
+}
@@ -11,12 +11,12 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
-	"go/types"
 	"html/template"
+	"io"
 	"io/ioutil"
-	"log"
 	"os"
 	"os/exec"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -33,11 +33,17 @@ var testReportHTMLTemplateStr []byte
 var testReportJsCodeStr []byte
 
 type Status struct {
-	Pass        int    `json:"pass,omitempty"`
-	Fail        int    `json:"fail,omitempty"`
-	Total       int    `json:"total,omitempty"`
-	Skip        int    `json:"skip,omitempty"`
-	ElapsedTime string `json:"elapsed_time,omitempty"`
+	Pass            int            `json:"pass"`
+	Fail            int            `json:"fail"`
+	Total           int            `json:"total"`
+	Skip            int            `json:"skip"`
+	Known           int            `json:"known"`
+	Flaky           int            `json:"flaky"`
+	ElapsedTime     string         `json:"elapsed_time"`
+	FailedPackages  int            `json:"failed_packages"`
+	PackageFailures map[string]int `json:"package_failures"`
+	Report          string         `json:"report,omitempty"`
+	FirstFailure    string         `json:"first_failure,omitempty"`
 }
 
 type (
@@ -48,7 +54,11 @@ type (
 		Package     string
 		Elapsed     float64
 		Output      string
+		Key         string
+		Value       string
 		Screenshots []string
+		ImportPath  string
+		FailedBuild string
 	}
 
 	testStatus struct {
@@ -61,10 +71,48 @@ type (
 		TestFileName       string
 		TestFunctionDetail testFunctionFilePos
 		Screenshots        []string
+		Attrs              map[string]string
+		Flaky              bool
+		Race               bool
+		History            []string
+		KnownFailure       *knownFailure
+		BaselineElapsed    float64
+		Slower             bool
+		TruncatedBytes     int
+		FullOutput         string
+		testFilePath       string
+		failed             bool
+	}
+
+	packageStatus struct {
+		Name            string
+		ElapsedTime     float64
+		Passed          bool
+		BaselineElapsed float64
+		Slower          bool
+		// Failed is set by the package level fail event, e.g. of a build failure, a failing
+		// TestMain or a timeout; FailedBuild is the failed build and Output the output of the
+		// package and of its build.
+		Failed      bool
+		FailedBuild string
+		Output      []string
+		// the times of the first and the last event of the package
+		started, finished time.Time
 	}
+
+	failureMessage struct {
+		File    string `json:"file"`
+		Line    int    `json:"line"`
+		Message string `json:"message"`
+	}
+
+	// Info is an entry of the run metadata shown in the report header. Type is text, link or
+	// timestamp; IsLink is kept for the templates written before the types.
 	Info struct {
-		Key, Value string
-		IsLink     bool
+		Key    string `yaml:"key" json:"key"`
+		Value  string `yaml:"value" json:"value"`
+		Type   string `yaml:"type" json:"type"`
+		IsLink bool   `yaml:"-" json:"-"`
 	}
 
 	templateData struct {
@@ -74,21 +122,46 @@ type (
 		NumOfTestPassed                int
 		NumOfTestFailed                int
 		NumOfTestSkipped               int
+		NumOfKnownFailures             int
 		NumOfTests                     int
 		TestDuration                   time.Duration
 		ReportTitle                    string
 		JsCode                         template.JS
+		CustomCSS                      template.CSS
+		ReportData                     template.JS
+		OutputBlocks                   template.JS
+		SplitOutput                    bool
+		CSPNonce                       string
+		Redactions                     int
 		numOfTestsPerGroup             int
+		groupBy                        string
 		OutputFilename                 string
 		InputFilename                  string
 		TestExecutionDate              string
 		ServerInfo                     []Info
+		GateResults                    []gateResult
+		History                        []historyRun
+		FlakyTests                     []*flakyTest
+		FixedKnownFailures             []*testStatus
+		PackageFailures                []*packageStatus
+		SlowerTests                    []*durationRegression
+		SlowerPackages                 []*durationRegression
+		Slowest                        *slowestPanel
+		PackageTree                    *packageNode
+		knownFailures                  []*knownFailure
+		htmlTemplate                   string
+		executionTime                  time.Time
 	}
 
 	testGroupData struct {
-		FailureIndicator string
-		SkippedIndicator string
-		TestResults      []*testStatus
+		Name               string
+		FailureIndicator   string
+		SkippedIndicator   string
+		NumOfTestPassed    int
+		NumOfTestFailed    int
+		NumOfTestSkipped   int
+		NumOfKnownFailures int
+		TestResults        []*testStatus
 	}
 
 	cmdFlags struct {
@@ -96,11 +169,59 @@ type (
 		titleFlag  string
 		sizeFlag   string
 		groupSize  int
+		groupBy    string
 		listFlag   string
 		inputFlag  string
 		outputFlag string
 		outputEnv  string
+		envFormat  string
+		jsonFlag   string
+		tapFlag    string
+		ctrfFlag   string
 		verbose    bool
+		formats    []string
+
+		markdownFlag        string
+		markdownMaxSize     int
+		markdownStepSummary bool
+
+		annotationsFlag       string
+		annotationsOutputFlag string
+
+		failOnFlag       []string
+		minPassRateFlag  float64
+		maxSkippedFlag   int
+		maxDurationFlag  time.Duration
+		requireTestsFlag []string
+
+		historyDir  string
+		historyRuns int
+
+		knownFailuresFlag string
+
+		baselineFlag     string
+		baselineHistory  bool
+		regressionFactor float64
+		regressionMin    time.Duration
+
+		slowestFlag int
+
+		templateFlag string
+		cssFlag      string
+
+		splitFlag string
+
+		stripColorsFlag bool
+
+		redactFlag    []string
+		redactEnvFlag []string
+
+		maxOutputPerTestFlag string
+		maxOutputTotalFlag   string
+		fullOutputFlag       bool
+
+		metadataFlag     string
+		autoMetadataFlag bool
 	}
 
 	goListJSONModule struct {
@@ -125,6 +246,7 @@ type (
 
 	testFileDetail struct {
 		FileName            string
+		FilePath            string
 		TestFunctionFilePos testFunctionFilePos
 	}
 
@@ -135,6 +257,10 @@ type (
 func main() {
 	rootCmd, _, _ := initRootCommand()
 	if err := rootCmd.Execute(); err != nil {
+		var gateErr *gateError
+		if errors.As(err, &gateErr) {
+			os.Exit(gateExitCode)
+		}
 		os.Exit(1)
 	}
 }
@@ -145,28 +271,55 @@ func initRootCommand() (*cobra.Command, *templateData, *cmdFlags) {
 	rootCmd := &cobra.Command{
 		Use:  "report",
 		Long: "convert json go test report to html",
-		RunE: func(cmd *cobra.Command, args []string) (e error) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 
 			startTime := time.Now()
 			if err := parseSizeFlag(tmplData, flags); err != nil {
 				return err
 			}
-			serverInfo := []Info{}
-			for _, info := range strings.Split(flags.serverInfo, ";") {
-				kv := strings.Split(info, "::")
-				if len(kv) > 1 {
-					var isLink bool
-					if strings.Contains(kv[1], "http") {
-						isLink = true
-					}
-					info := Info{Key: kv[0], Value: kv[1], IsLink: isLink}
-					serverInfo = append(serverInfo, info)
-
+			if !isEnvFormat(flags.envFormat) {
+				return fmt.Errorf("unknown env format %q; expected one of %s", flags.envFormat, strings.Join(envFormats, ", "))
+			}
+			if flags.historyRuns < 1 {
+				return fmt.Errorf("--history-runs must be at least 1, got %d", flags.historyRuns)
+			}
+			if flags.annotationsFlag != "" && flags.annotationsFlag != annotationsGitHub && flags.annotationsFlag != annotationsGitLab {
+				return fmt.Errorf("unknown annotations mode %q; expected %s or %s", flags.annotationsFlag, annotationsGitHub, annotationsGitLab)
+			}
+			if err := validateRegressionFlags(flags); err != nil {
+				return err
+			}
+			if flags.groupSize < 1 {
+				return fmt.Errorf("--groupSize must be at least 1, got %d", flags.groupSize)
+			}
+			if !isGroupBy(flags.groupBy) {
+				return fmt.Errorf("unknown group-by mode %q; expected one of %s", flags.groupBy, strings.Join(groupBys, ", "))
+			}
+			if flags.slowestFlag < 0 {
+				return fmt.Errorf("--slowest must not be negative, got %d", flags.slowestFlag)
+			}
+			gates, err := parseGateFlags(flags)
+			if err != nil {
+				return err
+			}
+			redactor, err := newRedactor(flags)
+			if err != nil {
+				return err
+			}
+			var metadataFile, autoMetadata []Info
+			if flags.metadataFlag != "" {
+				if metadataFile, err = readMetadataFile(flags.metadataFlag); err != nil {
+					return err
 				}
 			}
-			tmplData.ServerInfo = serverInfo
+			if flags.autoMetadataFlag {
+				autoMetadata = collectMetadata()
+			}
+			tmplData.ServerInfo = mergeMetadata(parseServerInfo(flags.serverInfo), metadataFile, autoMetadata)
+			redactor.redactMetadata(tmplData.ServerInfo)
 
 			tmplData.numOfTestsPerGroup = flags.groupSize
+			tmplData.groupBy = flags.groupBy
 			tmplData.ReportTitle = flags.titleFlag
 			tmplData.OutputFilename = flags.outputFlag
 			tmplData.OutputFilename = flags.outputFlag
@@ -174,52 +327,121 @@ func initRootCommand() (*cobra.Command, *templateData, *cmdFlags) {
 			// if err := checkIfStdinIsPiped(); err != nil {
 			// 	return err
 			// }
-			testReportHTMLTemplateFile, _ := os.Create(tmplData.OutputFilename)
-			reportFileWriter := bufio.NewWriter(testReportHTMLTemplateFile)
-			defer func() {
-				if err := reportFileWriter.Flush(); err != nil {
-					e = err
-				}
-				if err := testReportHTMLTemplateFile.Close(); err != nil {
-					e = err
-				}
-			}()
+			outputs, err := parseOutputFormats(flags, cmd.Flags().Changed("output"))
+			if err != nil {
+				return err
+			}
 			strStartTestTime := os.Getenv("START_TIME")
 			startTestTime, err := time.Parse(time.UnixDate, strStartTestTime)
 			if err != nil {
 				startTestTime = time.Now()
 			}
-			allPackageNames, allTests, err := readTestDataFromFile(tmplData.InputFilename, flags, cmd)
+			startTimeProvided := err == nil
+			if err := readCustomTemplates(tmplData, flags); err != nil {
+				return err
+			}
+			if flags.knownFailuresFlag != "" {
+				if tmplData.knownFailures, err = readKnownFailures(flags.knownFailuresFlag); err != nil {
+					return err
+				}
+			}
+			limiter, err := newOutputLimiter(flags, htmlReportPath(outputs))
+			if err != nil {
+				return err
+			}
+			allPackages, allTests, err := readTestDataFromFile(tmplData.InputFilename, flags, cmd, redactor, limiter)
 			if err != nil {
 				return errors.New("failed to read input file,err=" + err.Error() + "\n")
 			}
+			redactor.redactTests(allTests)
+			tmplData.Redactions = redactor.count
 			elapsedTestTime := time.Since(startTestTime)
 			strElapsedTestTime := os.Getenv("END_TIME")
 			endTestTime, err := time.Parse(time.UnixDate, strElapsedTestTime)
 			if err == nil {
 				elapsedTestTime = endTestTime.Sub(startTestTime)
 			}
+			if !startTimeProvided {
+				// the time since the start of the report would only measure the parsing
+				elapsedTestTime = runDuration(allPackages)
+			}
 			// used to the location of test functions in test go files by package and test function name.
 			var testFileDetailByPackage testFileDetailsByPackage
 			if flags.listFlag != "" {
 
 				testFileDetailByPackage, err = getAllDetails(flags.listFlag)
 			} else {
-				testFileDetailByPackage, err = getPackageDetails(allPackageNames)
+				testFileDetailByPackage, err = getPackageDetails(allPackages)
 			}
 			if err != nil {
 				return err
 			}
-			err = generateReport(tmplData, allTests, testFileDetailByPackage, elapsedTestTime, reportFileWriter, flags.outputEnv)
+			prepareReport(tmplData, allTests, testFileDetailByPackage, elapsedTestTime)
+			tmplData.PackageFailures = packageFailures(allTests, allPackages)
+			for _, status := range tmplData.FixedKnownFailures {
+				fmt.Fprintf(cmd.ErrOrStderr(), "[report] known failure %s.%s passed; remove it from %s\n", status.Package, status.TestName, flags.knownFailuresFlag)
+			}
+			if flags.slowestFlag > 0 {
+				tmplData.Slowest = newSlowestPanel(allTests, allPackages, flags.slowestFlag)
+			}
+			tmplData.PackageTree = newPackageTree(allTests, allPackages)
+			baseline, err := loadBaseline(flags, cmd)
 			if err != nil {
 				return err
 			}
+			if baseline != nil {
+				baseline.compare(tmplData, allTests, allPackages, flags.regressionFactor, flags.regressionMin)
+			}
+			if flags.historyDir != "" {
+				record := newHistoryRecord(tmplData, allTests, allPackages, tmplData.TestDuration)
+				if err := updateHistory(flags.historyDir, flags.historyRuns, record, tmplData, allTests); err != nil {
+					return fmt.Errorf("failed to update the test history: %w", err)
+				}
+			}
+			tmplData.GateResults = gates.evaluate(tmplData, allTests, allPackages)
+			r := &report{
+				tmplData:    tmplData,
+				allTests:    allTests,
+				allPackages: allPackages,
+				summary:     newJSONSummary(tmplData, allTests, allPackages),
+				flags:       flags,
+			}
+			if flags.stripColorsFlag {
+				r.summary.stripColors()
+			}
+			for _, output := range outputs {
+				if err := r.render(output); err != nil {
+					return err
+				}
+			}
+			status := newStatus(r.summary, tmplData.TestDuration, htmlReportPath(outputs))
+			envFile := flags.outputEnv
+			if githubOutput := os.Getenv("GITHUB_OUTPUT"); flags.envFormat == envFormatGitHubOutput && !cmd.Flags().Changed("env") && githubOutput != "" {
+				envFile = githubOutput
+			}
+			if err := writeStatus(&status, envFile, flags.envFormat); err != nil {
+				return fmt.Errorf("failed to write the status file %s: %w", envFile, err)
+			}
+			if stepSummaryFile := os.Getenv("GITHUB_STEP_SUMMARY"); flags.markdownStepSummary && stepSummaryFile != "" {
+				if err := appendMarkdownSummary(stepSummaryFile, renderMarkdownSummary(r.summary, flags.markdownMaxSize)); err != nil {
+					return err
+				}
+			}
+			if flags.annotationsFlag == annotationsGitHub {
+				if err := writeGitHubAnnotations(cmd.OutOrStdout(), collectAnnotations(r.summary)); err != nil {
+					return err
+				}
+			}
 
 			elapsedTime := time.Since(startTime)
 			elapsedTimeMsg := []byte(fmt.Sprintf("[report] finished in %s\n", elapsedTime))
 			if _, err := cmd.OutOrStdout().Write(elapsedTimeMsg); err != nil {
 				return err
 			}
+			if err := newGateError(tmplData.GateResults); err != nil {
+				cmd.SilenceUsage = true
+				return err
+			}
 			return nil
 		},
 	}
@@ -227,7 +449,15 @@ func initRootCommand() (*cobra.Command, *templateData, *cmdFlags) {
 		"serverInfo",
 		"f",
 		"",
-		"the server info shown in the test report")
+		"the server info shown in the test report, as key::value entries separated by ; (escaped as \\;)")
+	rootCmd.PersistentFlags().StringVar(&flags.metadataFlag,
+		"metadata",
+		"",
+		"a YAML or JSON file of run metadata shown in the report header; a list of key, value and type (text, link or timestamp)")
+	rootCmd.PersistentFlags().BoolVar(&flags.autoMetadataFlag,
+		"auto-metadata",
+		false,
+		"show the git commit and branch, the Go version, the host and the CI build in the report header")
 	rootCmd.PersistentFlags().StringVarP(&flags.titleFlag,
 		"title",
 		"t",
@@ -242,7 +472,11 @@ func initRootCommand() (*cobra.Command, *templateData, *cmdFlags) {
 		"groupSize",
 		"g",
 		20,
-		"the number of tests per test group indicator")
+		"the number of tests per test group indicator when grouping by chunk")
+	rootCmd.PersistentFlags().StringVar(&flags.groupBy,
+		"group-by",
+		groupByChunk,
+		"what the test group indicators represent: chunk, package, file, status or owner")
 	rootCmd.PersistentFlags().StringVarP(&flags.listFlag,
 		"list",
 		"l",
@@ -253,33 +487,171 @@ func initRootCommand() (*cobra.Command, *templateData, *cmdFlags) {
 		"o",
 		"report.html",
 		"the HTML output file")
+	rootCmd.PersistentFlags().StringArrayVar(&flags.formats,
+		"format",
+		nil,
+		"an additional output written as name=path, e.g. json=summary.json; may be repeated (formats: "+strings.Join(rendererNames(), ", ")+")")
 	rootCmd.PersistentFlags().StringVarP(&flags.outputEnv,
 		"env",
 		"e",
 		"status.env",
 		"env file with total,pass,fail,skip status")
-	rootCmd.PersistentFlags().StringVarP(&flags.inputFlag,
+	rootCmd.PersistentFlags().StringVar(&flags.envFormat,
+		"env-format",
+		envFormatShell,
+		"the format of the env file; one of "+strings.Join(envFormats, ", "))
+	rootCmd.Flags().StringVarP(&flags.inputFlag,
 		"input",
 		"i",
 		"",
 		"the json input file")
-	rootCmd.MarkPersistentFlagRequired("input")
+	rootCmd.MarkFlagRequired("input")
+	rootCmd.PersistentFlags().StringVar(&flags.jsonFlag,
+		"json-summary",
+		"",
+		"the machine-readable JSON summary output file")
+	rootCmd.PersistentFlags().StringVar(&flags.tapFlag,
+		"tap",
+		"",
+		"the TAP version 13 output file")
+	rootCmd.PersistentFlags().StringVar(&flags.ctrfFlag,
+		"ctrf",
+		"",
+		"the Common Test Report Format (CTRF) JSON output file")
+	rootCmd.PersistentFlags().StringVar(&flags.markdownFlag,
+		"markdown",
+		"",
+		"the markdown summary output file, e.g. for pull request comments")
+	rootCmd.PersistentFlags().IntVar(&flags.markdownMaxSize,
+		"markdown-max-size",
+		defaultMarkdownMaxSize,
+		"the maximum size (in bytes) of the markdown summary; 0 disables the limit")
+	rootCmd.PersistentFlags().BoolVar(&flags.markdownStepSummary,
+		"markdown-step-summary",
+		false,
+		"append the markdown summary to $GITHUB_STEP_SUMMARY when that variable is set")
+	rootCmd.PersistentFlags().StringVar(&flags.annotationsFlag,
+		"annotations",
+		"",
+		"annotate failed tests for CI; github prints workflow commands, gitlab writes a Code Quality report")
+	rootCmd.PersistentFlags().StringVar(&flags.annotationsOutputFlag,
+		"annotations-output",
+		defaultGitLabCodeQualityFile,
+		"the GitLab Code Quality report file written by --annotations gitlab")
+	rootCmd.PersistentFlags().StringSliceVar(&flags.failOnFlag,
+		"fail-on",
+		nil,
+		"exit with a non-zero code if any test matches; one or more of "+strings.Join(failOnConditions, ", "))
+	rootCmd.PersistentFlags().Float64Var(&flags.minPassRateFlag,
+		"min-pass-rate",
+		0,
+		"the minimum percentage of passed tests, excluding skipped tests")
+	rootCmd.PersistentFlags().IntVar(&flags.maxSkippedFlag,
+		"max-skipped",
+		-1,
+		"the maximum number of skipped tests; -1 disables the check")
+	rootCmd.PersistentFlags().DurationVar(&flags.maxDurationFlag,
+		"max-duration",
+		0,
+		"the maximum duration of the test run, e.g. 10m")
+	rootCmd.PersistentFlags().StringArrayVar(&flags.requireTestsFlag,
+		"require-tests",
+		nil,
+		"a regular expression that must match the name of at least one test; may be repeated")
+	rootCmd.PersistentFlags().StringVar(&flags.historyDir,
+		"history-dir",
+		"",
+		"the directory of the run history; every run is appended to it and trends are shown in the report")
+	rootCmd.PersistentFlags().IntVar(&flags.historyRuns,
+		"history-runs",
+		defaultHistoryRuns,
+		"the number of recent runs shown in the trend charts")
+	rootCmd.PersistentFlags().StringVar(&flags.knownFailuresFlag,
+		"known-failures",
+		"",
+		"a YAML or JSON file of known failures; matching failed tests are not counted as failed")
+	rootCmd.PersistentFlags().StringVar(&flags.baselineFlag,
+		"baseline",
+		"",
+		"a previous go test -json output to compare the test durations with")
+	rootCmd.PersistentFlags().BoolVar(&flags.baselineHistory,
+		"baseline-history",
+		false,
+		"compare the test durations with their median in the run history of --history-dir")
+	rootCmd.PersistentFlags().Float64Var(&flags.regressionFactor,
+		"regression-factor",
+		defaultRegressionFactor,
+		"flag tests and packages whose duration grew by at least this factor")
+	rootCmd.PersistentFlags().DurationVar(&flags.regressionMin,
+		"regression-min",
+		defaultRegressionMinimum,
+		"flag tests and packages whose duration grew by at least this much")
+	rootCmd.PersistentFlags().IntVar(&flags.slowestFlag,
+		"slowest",
+		defaultSlowestTests,
+		"the number of slowest tests and packages shown in the report; 0 hides them")
+	rootCmd.PersistentFlags().StringVar(&flags.templateFlag,
+		"template",
+		"",
+		"an HTML template file that replaces the built-in report layout")
+	rootCmd.PersistentFlags().StringVar(&flags.cssFlag,
+		"css",
+		"",
+		"a CSS file added to the styles of the report")
+	rootCmd.PersistentFlags().StringVar(&flags.splitFlag,
+		"split",
+		"",
+		"write the report as DIR/index.html with the test data in DIR/data, to be served by a web server")
+	rootCmd.PersistentFlags().BoolVar(&flags.stripColorsFlag,
+		"strip-colors",
+		false,
+		"remove ANSI color codes from the test output in the JSON summary and the text formats")
+	rootCmd.PersistentFlags().StringArrayVar(&flags.redactFlag,
+		"redact",
+		nil,
+		"a regular expression of secrets to replace with "+redactedText+" in the test output; may be repeated")
+	rootCmd.PersistentFlags().StringArrayVar(&flags.redactEnvFlag,
+		"redact-env",
+		nil,
+		"the name of an environment variable whose value is replaced with "+redactedText+" in the test output; may be repeated")
+	rootCmd.PersistentFlags().StringVar(&flags.maxOutputPerTestFlag,
+		"max-output-per-test",
+		"",
+		"the output kept per test, e.g. 1MB; the beginning and the end are kept, the rest is truncated")
+	rootCmd.PersistentFlags().StringVar(&flags.maxOutputTotalFlag,
+		"max-output-total",
+		"",
+		"the output kept for all tests together, e.g. 50MB; output past it is truncated")
+	rootCmd.PersistentFlags().BoolVar(&flags.fullOutputFlag,
+		"full-output",
+		false,
+		"write the full output of truncated tests next to the HTML report and link to it")
 	rootCmd.PersistentFlags().BoolVarP(&flags.verbose,
 		"verbose",
 		"v",
 		false,
 		"while processing, show the complete output from go test ")
+	rootCmd.AddCommand(newFlakyCommand(flags))
+	rootCmd.AddCommand(newDiffCommand(flags))
+	rootCmd.AddCommand(newVersionCommand())
 
 	return rootCmd, tmplData, flags
 }
 
-func readTestDataFromFile(inputFile string, flags *cmdFlags, cmd *cobra.Command) (allPackageNames map[string]*types.Nil, allTests map[string]*testStatus, e error) {
+// readTestDataFromFile reads the go test -json output of inputFile. Every chunk of output is
+// redacted by r, if not nil, before the outputs of the tests are truncated by the limiter, if not
+// nil, so that a secret is never split by the truncation.
+func readTestDataFromFile(inputFile string, flags *cmdFlags, cmd *cobra.Command, r *redactor, limiter *outputLimiter) (allPackages map[string]*packageStatus, allTests map[string]*testStatus, e error) {
 	allTests = map[string]*testStatus{}
-	allPackageNames = map[string]*types.Nil{}
+	allPackages = map[string]*packageStatus{}
 	testReportJsCodeByte, err := ioutil.ReadFile(inputFile)
 	if err != nil {
-		log.Fatalf("failed reading data from file: %s", err)
+		return nil, nil, err
 	}
+	// the output of the builds by import path, and of the packages outside of their tests
+	buildOutputs := map[string][]string{}
+	packageOutputs := map[string][]string{}
+	started, finished := map[string]time.Time{}, map[string]time.Time{}
 	testReportJsCodeStr := string(testReportJsCodeByte)
 	for _, lineInput := range strings.Split(testReportJsCodeStr, "\n") {
 		if len(lineInput) < 1 {
@@ -289,6 +661,13 @@ func readTestDataFromFile(inputFile string, flags *cmdFlags, cmd *cobra.Command)
 		if err := json.Unmarshal([]byte(lineInput), goTestOutputRow); err != nil {
 			return nil, nil, err
 		}
+		goTestOutputRow.Output = r.redact(goTestOutputRow.Output)
+		if t, err := time.Parse(time.RFC3339Nano, goTestOutputRow.Time); err == nil && goTestOutputRow.Package != "" {
+			if _, exists := started[goTestOutputRow.Package]; !exists {
+				started[goTestOutputRow.Package] = t
+			}
+			finished[goTestOutputRow.Package] = t
+		}
 		if goTestOutputRow.TestName != "" {
 			var status *testStatus
 			key := goTestOutputRow.Package + "." + goTestOutputRow.TestName
@@ -309,9 +688,29 @@ func readTestDataFromFile(inputFile string, flags *cmdFlags, cmd *cobra.Command)
 				if goTestOutputRow.Action == "skip" {
 					status.Skipped = true
 				}
+				if goTestOutputRow.Action == "fail" {
+					status.failed = true
+				}
+				// a test that both passed and failed was run more than once, e.g. with -count
+				status.Flaky = status.Passed && status.failed
 				status.ElapsedTime = goTestOutputRow.Elapsed
+				if err := limiter.end(status); err != nil {
+					return nil, nil, err
+				}
+			}
+			if strings.Contains(goTestOutputRow.Output, "WARNING: DATA RACE") || strings.Contains(goTestOutputRow.Output, "race detected during execution of test") {
+				status.Race = true
+			}
+			if _, exists := allPackages[goTestOutputRow.Package]; !exists {
+				allPackages[goTestOutputRow.Package] = &packageStatus{Name: goTestOutputRow.Package}
+			}
+			if goTestOutputRow.Action == "attr" {
+				if status.Attrs == nil {
+					status.Attrs = map[string]string{}
+				}
+				status.Attrs[goTestOutputRow.Key] = goTestOutputRow.Value
+				continue
 			}
-			allPackageNames[goTestOutputRow.Package] = nil
 			if strings.Contains(goTestOutputRow.Output, "--- PASS:") {
 				goTestOutputRow.Output = strings.TrimSpace(goTestOutputRow.Output)
 			}
@@ -320,11 +719,105 @@ func readTestDataFromFile(inputFile string, flags *cmdFlags, cmd *cobra.Command)
 				screenshots := strings.TrimSpace(strings.Split(goTestOutputRow.Output, screenshotKeyWord)[1])
 				goTestOutputRow.Screenshots = append(goTestOutputRow.Screenshots, strings.Split(screenshots[1:len(screenshots)-1], " ")...)
 			}
-			status.Output = append(status.Output, goTestOutputRow.Output)
+			if err := limiter.append(status, goTestOutputRow.Output); err != nil {
+				return nil, nil, err
+			}
 			status.Screenshots = append(status.Screenshots, goTestOutputRow.Screenshots...)
+		} else if goTestOutputRow.Action == "build-output" {
+			buildOutputs[goTestOutputRow.ImportPath] = append(buildOutputs[goTestOutputRow.ImportPath], goTestOutputRow.Output)
+		} else if goTestOutputRow.Package != "" {
+			pkg := allPackages[goTestOutputRow.Package]
+			switch goTestOutputRow.Action {
+			case "output":
+				packageOutputs[goTestOutputRow.Package] = append(packageOutputs[goTestOutputRow.Package], goTestOutputRow.Output)
+			case "pass", "fail":
+				// a package that failed without running tests, e.g. because it does not build, is
+				// only known from this row
+				if pkg == nil && goTestOutputRow.Action == "fail" {
+					pkg = &packageStatus{Name: goTestOutputRow.Package}
+					allPackages[goTestOutputRow.Package] = pkg
+				}
+				if pkg == nil {
+					continue
+				}
+				// the package level row carries the elapsed time of the whole test binary
+				pkg.Passed = goTestOutputRow.Action == "pass"
+				pkg.Failed = goTestOutputRow.Action == "fail"
+				pkg.ElapsedTime = goTestOutputRow.Elapsed
+				if pkg.Failed {
+					pkg.FailedBuild = goTestOutputRow.FailedBuild
+					pkg.Output = append(append([]string{}, buildOutputs[goTestOutputRow.FailedBuild]...), packageOutputs[goTestOutputRow.Package]...)
+				}
+			}
 		}
 	}
-	return allPackageNames, allTests, nil
+	for name, pkg := range allPackages {
+		pkg.started, pkg.finished = started[name], finished[name]
+	}
+	if err := limiter.finish(); err != nil {
+		return nil, nil, err
+	}
+	return allPackages, allTests, nil
+}
+
+// packageFailures returns the packages that failed without a failed test, e.g. because they do
+// not build, TestMain failed or the test binary timed out, sorted by name.
+func packageFailures(allTests map[string]*testStatus, allPackages map[string]*packageStatus) []*packageStatus {
+	explained := map[string]bool{}
+	for _, status := range allTests {
+		if status.statusText() == "fail" {
+			explained[status.Package] = true
+		}
+	}
+	var failures []*packageStatus
+	for name, pkg := range allPackages {
+		if pkg.Failed && !explained[name] {
+			failures = append(failures, pkg)
+		}
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Name < failures[j].Name
+	})
+	return failures
+}
+
+var failureLocationRegExp = regexp.MustCompile(`^(\s*)([\w.\-]+\.go):(\d+): ?(.*)$`)
+
+// parseFailureMessages extracts the "file.go:line: message" entries written by t.Error, t.Fatal,
+// t.Skip and t.Log from the output of a test. Indented lines following an entry are treated as
+// its continuation.
+func parseFailureMessages(output []string) []failureMessage {
+	var messages []failureMessage
+	current, indent := -1, ""
+	for _, chunk := range output {
+		for _, line := range strings.Split(strings.TrimRight(chunk, "\n"), "\n") {
+			if m := failureLocationRegExp.FindStringSubmatch(line); m != nil {
+				lineNum, _ := strconv.Atoi(m[3])
+				messages = append(messages, failureMessage{File: m[2], Line: lineNum, Message: m[4]})
+				current, indent = len(messages)-1, m[1]
+				continue
+			}
+			trimmed := strings.TrimSpace(line)
+			if current >= 0 && strings.HasPrefix(line, indent+" ") &&
+				!strings.HasPrefix(trimmed, "--- ") && !strings.HasPrefix(trimmed, "=== ") {
+				messages[current].Message += "\n" + strings.TrimPrefix(strings.TrimPrefix(line, indent), "    ")
+				continue
+			}
+			current = -1
+		}
+	}
+	return messages
+}
+
+// statusText returns the go test action that concluded the test: "pass", "fail" or "skip".
+func (s *testStatus) statusText() string {
+	if s.Passed {
+		return "pass"
+	}
+	if s.Skipped {
+		return "skip"
+	}
+	return "fail"
 }
 
 func getAllDetails(listFile string) (testFileDetailsByPackage, error) {
@@ -350,12 +843,12 @@ func getAllDetails(listFile string) (testFileDetailsByPackage, error) {
 	return testFileDetailByPackage, nil
 }
 
-func getPackageDetails(allPackageNames map[string]*types.Nil) (testFileDetailsByPackage, error) {
+func getPackageDetails(allPackages map[string]*packageStatus) (testFileDetailsByPackage, error) {
 	var testFileDetailByPackage testFileDetailsByPackage
 	ctx := context.Background()
 	g, ctx := errgroup.WithContext(ctx)
 	details := make(chan testFileDetailsByPackage)
-	for packageName := range allPackageNames {
+	for packageName := range allPackages {
 		name := packageName
 		g.Go(func() error {
 			testFileDetailsByTest, err := getTestDetails(name)
@@ -376,7 +869,7 @@ func getPackageDetails(allPackageNames map[string]*types.Nil) (testFileDetailsBy
 		close(details)
 	}()
 
-	testFileDetailByPackage = make(testFileDetailsByPackage, len(allPackageNames))
+	testFileDetailByPackage = make(testFileDetailsByPackage, len(allPackages))
 	for d := range details {
 		for packageName, testFileDetailsByTest := range d {
 			testFileDetailByPackage[packageName] = testFileDetailsByTest
@@ -427,6 +920,7 @@ func getFileDetails(goListJSON *goListJSON) (testFileDetailsByTest, error) {
 				lineNum, _ := strconv.Atoi(fileDetails[1])
 				colNum, _ := strconv.Atoi(fileDetails[2])
 				testFileDetail.FileName = fileDetails[0]
+				testFileDetail.FilePath = fileSetPos.Filename
 				testFileDetail.TestFunctionFilePos = testFunctionFilePos{
 					Line: lineNum,
 					Col:  colNum,
@@ -455,24 +949,30 @@ func (t byName) Less(i, j int) bool {
 	return t[i].name < t[j].name
 }
 
-func generateReport(tmplData *templateData, allTests map[string]*testStatus, testFileDetailByPackage testFileDetailsByPackage, elapsedTestTime time.Duration, reportFileWriter *bufio.Writer, outptuEnvFile string) error {
-	// // read the html template from the generated embedded asset go file
-	// testReportHTMLTemplateStr, err := ioutil.ReadFile("../dist/report.html.template")
-	tpl := template.New("report.html.template")
-	tpl, err := tpl.Parse(string(testReportHTMLTemplateStr))
-	if err != nil {
-		return err
+// sortedTests returns all tests ordered by package and then by test name.
+func sortedTests(allTests map[string]*testStatus) []*testStatus {
+	tests := make([]*testStatus, 0, len(allTests))
+	for _, status := range allTests {
+		tests = append(tests, status)
 	}
-	// testReportJsCodeStr, err := ioutil.ReadFile("../dist/report.js")
-	// if err != nil {
-	// 	log.Panicf("failed reading data from file: %s", err)
-	// }
+	sort.Slice(tests, func(i, j int) bool {
+		if tests[i].Package != tests[j].Package {
+			return tests[i].Package < tests[j].Package
+		}
+		return tests[i].TestName < tests[j].TestName
+	})
+	return tests
+}
+
+// prepareReport adds the test file details to the tests, arranges them into test groups and
+// counts the results. It is run once, before any of the output formats is rendered.
+func prepareReport(tmplData *templateData, allTests map[string]*testStatus, testFileDetailByPackage testFileDetailsByPackage, elapsedTestTime time.Duration) {
 	tmplData.NumOfTestPassed = 0
 	tmplData.NumOfTestFailed = 0
 	tmplData.NumOfTestSkipped = 0
-	tmplData.JsCode = template.JS(testReportJsCodeStr)
-	tgCounter := 0
-	tgID := 0
+	tmplData.NumOfKnownFailures = 0
+	tmplData.FixedKnownFailures = nil
+	classifyKnownFailures(allTests, tmplData.knownFailures)
 
 	// sort the allTests map by test name (this will produce a consistent order when iterating through the map)
 	var tests []testRef
@@ -481,50 +981,61 @@ func generateReport(tmplData *templateData, allTests map[string]*testStatus, tes
 	}
 
 	sort.Sort(byName(tests))
+	sorted := make([]*testStatus, 0, len(tests))
 	for _, test := range tests {
 		status := allTests[test.key]
-		if len(tmplData.TestResults) == tgID {
-			tmplData.TestResults = append(tmplData.TestResults, &testGroupData{})
-		}
 		// add file info(name and position; line and col) associated with the test function
 		testFileInfo := testFileDetailByPackage[status.Package][status.TestName]
+		if testFileInfo == nil {
+			// subtests are declared inside the function of their top level test
+			testFileInfo = testFileDetailByPackage[status.Package][strings.SplitN(status.TestName, "/", 2)[0]]
+		}
 		if testFileInfo != nil {
 			status.TestFileName = testFileInfo.FileName
+			status.testFilePath = testFileInfo.FilePath
 			status.TestFunctionDetail = testFileInfo.TestFunctionFilePos
 		}
-		tmplData.TestResults[tgID].TestResults = append(tmplData.TestResults[tgID].TestResults, status)
-		if !status.Passed {
-			if !status.Skipped {
-				tmplData.TestResults[tgID].FailureIndicator = "failed"
-				tmplData.NumOfTestFailed++
-			} else {
-				tmplData.TestResults[tgID].SkippedIndicator = "skipped"
-				tmplData.NumOfTestSkipped++
-			}
-		} else {
-			tmplData.NumOfTestPassed++
+		sorted = append(sorted, status)
+		if status.isFixedKnownFailure() {
+			tmplData.FixedKnownFailures = append(tmplData.FixedKnownFailures, status)
 		}
-		tgCounter++
 	}
-	tmplData.NumOfTests = tmplData.NumOfTestPassed + tmplData.NumOfTestFailed + tmplData.NumOfTestSkipped
-	status := Status{}
-	status.Total = tmplData.NumOfTests
-	status.Pass = tmplData.NumOfTestPassed
-	status.Skip = tmplData.NumOfTestSkipped
-	status.Fail = tmplData.NumOfTestFailed
-
-	writeStatus(&status, outptuEnvFile)
-
+	tmplData.TestResults = groupTests(sorted, tmplData.groupBy, tmplData.numOfTestsPerGroup)
+	for _, group := range tmplData.TestResults {
+		tmplData.NumOfTestPassed += group.NumOfTestPassed
+		tmplData.NumOfTestFailed += group.NumOfTestFailed
+		tmplData.NumOfTestSkipped += group.NumOfTestSkipped
+		tmplData.NumOfKnownFailures += group.NumOfKnownFailures
+	}
+	tmplData.NumOfTests = tmplData.NumOfTestPassed + tmplData.NumOfTestFailed + tmplData.NumOfTestSkipped + tmplData.NumOfKnownFailures
 	tmplData.TestDuration = elapsedTestTime.Round(time.Millisecond)
 	td := time.Now()
+	tmplData.executionTime = td
 	tmplData.TestExecutionDate = fmt.Sprintf("%s %d, %d %02d:%02d:%02d",
 		td.Month(), td.Day(), td.Year(), td.Hour(), td.Minute(), td.Second())
-	status.ElapsedTime = tmplData.TestExecutionDate
-	writeStatus(&status, outptuEnvFile)
-	if err := tpl.Execute(reportFileWriter, tmplData); err != nil {
+}
+
+func renderHTMLReport(w io.Writer, tmplData *templateData) error {
+	// // read the html template from the generated embedded asset go file
+	// testReportHTMLTemplateStr, err := ioutil.ReadFile("../dist/report.html.template")
+	tpl, err := newReportTemplate(tmplData.htmlTemplate)
+	if err != nil {
 		return err
 	}
-	return nil
+	// testReportJsCodeStr, err := ioutil.ReadFile("../dist/report.js")
+	// if err != nil {
+	// 	log.Panicf("failed reading data from file: %s", err)
+	// }
+	tmplData.JsCode = template.JS(testReportJsCodeStr)
+	if tmplData.CSPNonce, err = newCSPNonce(); err != nil {
+		return err
+	}
+	if !tmplData.SplitOutput {
+		if err := encodeReportData(tmplData); err != nil {
+			return err
+		}
+	}
+	return tpl.Execute(w, tmplData)
 }
 
 func parseSizeFlag(tmplData *templateData, flags *cmdFlags) error {
@@ -555,6 +1066,24 @@ func parseSizeFlag(tmplData *templateData, flags *cmdFlags) error {
 	return nil
 }
 
+// writeOutputFile creates the file and writes it using the given write function.
+func writeOutputFile(filename string, write func(w io.Writer) error) (e error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(f)
+	defer func() {
+		if err := writer.Flush(); err != nil && e == nil {
+			e = err
+		}
+		if err := f.Close(); err != nil && e == nil {
+			e = err
+		}
+	}()
+	return write(writer)
+}
+
 func checkIfStdinIsPiped() error {
 	stat, err := os.Stdin.Stat()
 	if err != nil {
@@ -565,8 +1094,3 @@ func checkIfStdinIsPiped() error {
 	}
 	return errors.New("ERROR: missing ≪ stdin ≫ pipe")
 }
-
-func writeStatus(status *Status, outptuEnvFile string) {
-	content := []byte(fmt.Sprintf("export TOTAL=%d\nexport PASS=%d\nexport FAIL=%d\nexport SKIP=%d\n", status.Total, status.Pass, status.Fail, status.Skip))
-	_ = ioutil.WriteFile(outptuEnvFile, content, 0644)
-}
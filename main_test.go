@@ -1,17 +1,31 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// reportArgs returns the arguments of a report of testdata/simple.json written to a temporary
+// directory, followed by args.
+func reportArgs(t *testing.T, args ...string) []string {
+	dir := t.TempDir()
+	return append([]string{
+		"--input", filepath.Join("testdata", "simple.json"),
+		"--list", filepath.Join("testdata", "empty_list.json"),
+		"--output", filepath.Join(dir, "report.html"),
+		"--env", filepath.Join(dir, "status.env"),
+	}, args...)
+}
+
 func TestVersionCommand(t *testing.T) {
 	assertions := assert.New(t)
 	buffer := bytes.NewBufferString("")
@@ -30,9 +44,9 @@ func TestTitleFlag(t *testing.T) {
 	buffer := bytes.NewBufferString("")
 	rootCmd, tmplData, _ := initRootCommand()
 	rootCmd.SetOut(buffer)
-	rootCmd.SetArgs([]string{"--title", "Sample Test Report"})
+	rootCmd.SetArgs(reportArgs(t, "--title", "Sample Test Report"))
 	rootCmdErr := rootCmd.Execute()
-	assertions.Error(rootCmdErr)
+	assertions.Nil(rootCmdErr)
 	output, readErr := ioutil.ReadAll(buffer)
 	assertions.Nil(readErr)
 	assertions.Equal("Sample Test Report", tmplData.ReportTitle)
@@ -55,9 +69,9 @@ func TestSizeFlag(t *testing.T) {
 	buffer := bytes.NewBufferString("")
 	rootCmd, tmplData, flags := initRootCommand()
 	rootCmd.SetOut(buffer)
-	rootCmd.SetArgs([]string{"--size", "24"})
+	rootCmd.SetArgs(reportArgs(t, "--size", "24"))
 	rootCmdErr := rootCmd.Execute()
-	assertions.Error(rootCmdErr)
+	assertions.Nil(rootCmdErr)
 	output, readErr := ioutil.ReadAll(buffer)
 	assertions.Nil(readErr)
 	assertions.Equal("24", flags.sizeFlag)
@@ -71,9 +85,9 @@ func TestSizeFlagWithFullDimensions(t *testing.T) {
 	buffer := bytes.NewBufferString("")
 	rootCmd, tmplData, flags := initRootCommand()
 	rootCmd.SetOut(buffer)
-	rootCmd.SetArgs([]string{"--size", "24x16"})
+	rootCmd.SetArgs(reportArgs(t, "--size", "24x16"))
 	rootCmdErr := rootCmd.Execute()
-	assertions.Error(rootCmdErr)
+	assertions.Nil(rootCmdErr)
 	output, readErr := ioutil.ReadAll(buffer)
 	assertions.Nil(readErr)
 	assertions.Equal("24x16", flags.sizeFlag)
@@ -98,9 +112,9 @@ func TestGroupSizeFlag(t *testing.T) {
 	buffer := bytes.NewBufferString("")
 	rootCmd, tmplData, _ := initRootCommand()
 	rootCmd.SetOut(buffer)
-	rootCmd.SetArgs([]string{"--groupSize", "32"})
+	rootCmd.SetArgs(reportArgs(t, "--groupSize", "32"))
 	rootCmdErr := rootCmd.Execute()
-	assertions.Error(rootCmdErr)
+	assertions.Nil(rootCmdErr)
 	output, readErr := ioutil.ReadAll(buffer)
 	assertions.Nil(readErr)
 	assertions.Equal(32, tmplData.numOfTestsPerGroup)
@@ -123,12 +137,14 @@ func TestGroupOutputFlag(t *testing.T) {
 	buffer := bytes.NewBufferString("")
 	rootCmd, tmplData, _ := initRootCommand()
 	rootCmd.SetOut(buffer)
-	rootCmd.SetArgs([]string{"--output", "test_file.html"})
+	outputFile := filepath.Join(t.TempDir(), "test_file.html")
+	rootCmd.SetArgs(reportArgs(t, "--output", outputFile))
 	rootCmdErr := rootCmd.Execute()
-	assertions.Error(rootCmdErr)
+	assertions.Nil(rootCmdErr)
 	output, readErr := ioutil.ReadAll(buffer)
 	assertions.Nil(readErr)
-	assertions.Equal("test_file.html", tmplData.OutputFilename)
+	assertions.Equal(outputFile, tmplData.OutputFilename)
+	assertions.FileExists(outputFile)
 	assertions.NotEmpty(output)
 }
 
@@ -180,7 +196,7 @@ func TestGetAllDetails(t *testing.T) {
 	assertions.Len(testFileDetailsByPackage, 1)
 }
 
-func TestGenerateReport(t *testing.T) {
+func TestPrepareAndRenderReport(t *testing.T) {
 	assertions := assert.New(t)
 	tmplData := &templateData{
 		TestResultGroupIndicatorWidth:  "20px",
@@ -252,9 +268,10 @@ func TestGenerateReport(t *testing.T) {
 		},
 	}
 	elapsedTestTime := 3 * time.Second
-	writer := bufio.NewWriter(&bytes.Buffer{})
-	err := generateReport(tmplData, allTests, testFileDetailsByPackage, elapsedTestTime, writer)
-	assertions.Nil(err)
+	prepareReport(tmplData, allTests, testFileDetailsByPackage, elapsedTestTime)
+	report := &bytes.Buffer{}
+	assertions.Nil(renderHTMLReport(report, tmplData))
+	assertions.Contains(report.String(), "<title>test-title</title>")
 	assertions.Equal(2, tmplData.NumOfTestPassed)
 	assertions.Equal(1, tmplData.NumOfTestFailed)
 	assertions.Equal(1, tmplData.NumOfTestSkipped)
@@ -326,3 +343,162 @@ func TestParseSizeFlagIfMalformedSize(t *testing.T) {
 	assertions.Error(err)
 	assertions.Equal(err.Error(), `malformed size value; only one x is allowed if specifying with and height`)
 }
+
+func TestReadTestDataFromFile(t *testing.T) {
+	assertions := assert.New(t)
+	data := `{"Action":"run","Package":"pkg/a","Test":"TestA"}
+{"Action":"attr","Package":"pkg/a","Test":"TestA","Key":"owner","Value":"team-x"}
+{"Action":"output","Package":"pkg/a","Test":"TestA","Output":"    a_test.go:3: boom\n"}
+{"Action":"fail","Package":"pkg/a","Test":"TestA","Elapsed":0.25}
+{"Action":"output","Package":"pkg/a","Output":"FAIL\tpkg/a\t0.300s\n"}
+{"Action":"fail","Package":"pkg/a","Elapsed":0.3}
+{"Action":"skip","Package":"pkg/b","Elapsed":0}
+`
+	inputFile := filepath.Join(t.TempDir(), "input.json")
+	if err := ioutil.WriteFile(inputFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	allPackages, allTests, err := readTestDataFromFile(inputFile, &cmdFlags{}, nil, nil, nil)
+	assertions.Nil(err)
+	assertions.Len(allPackages, 1)
+	assertions.Equal(0.3, allPackages["pkg/a"].ElapsedTime)
+	assertions.False(allPackages["pkg/a"].Passed)
+	assertions.Len(allTests, 1)
+	assertions.Equal(0.25, allTests["pkg/a.TestA"].ElapsedTime)
+	assertions.Equal(map[string]string{"owner": "team-x"}, allTests["pkg/a.TestA"].Attrs)
+	assertions.Contains(allTests["pkg/a.TestA"].Output, "    a_test.go:3: boom\n")
+}
+
+func TestParseFailureMessages(t *testing.T) {
+	assertions := assert.New(t)
+	output := []string{
+		"=== RUN   TestA\n",
+		"    a_test.go:12: expected 1\n",
+		"        got 2\n",
+		"    helpers_test.go:7: second failure\n",
+		"--- FAIL: TestA (0.00s)\n",
+	}
+	messages := parseFailureMessages(output)
+	assertions.Equal([]failureMessage{
+		{File: "a_test.go", Line: 12, Message: "expected 1\ngot 2"},
+		{File: "helpers_test.go", Line: 7, Message: "second failure"},
+	}, messages)
+	assertions.Empty(parseFailureMessages([]string{"=== RUN   TestB\n", "--- PASS: TestB (0.00s)"}))
+}
+
+func TestReadTestDataFromFileDetectsFlakyAndRaceTests(t *testing.T) {
+	assertions := assert.New(t)
+	data := `{"Action":"run","Package":"pkg/a","Test":"TestFlaky"}
+{"Action":"fail","Package":"pkg/a","Test":"TestFlaky","Elapsed":0.1}
+{"Action":"run","Package":"pkg/a","Test":"TestFlaky"}
+{"Action":"pass","Package":"pkg/a","Test":"TestFlaky","Elapsed":0.1}
+{"Action":"output","Package":"pkg/a","Test":"TestRace","Output":"WARNING: DATA RACE\n"}
+{"Action":"fail","Package":"pkg/a","Test":"TestRace","Elapsed":0.1}
+`
+	inputFile := filepath.Join(t.TempDir(), "input.json")
+	if err := ioutil.WriteFile(inputFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, allTests, err := readTestDataFromFile(inputFile, &cmdFlags{}, nil, nil, nil)
+	assertions.Nil(err)
+	assertions.True(allTests["pkg/a.TestFlaky"].Flaky)
+	assertions.False(allTests["pkg/a.TestFlaky"].Race)
+	assertions.True(allTests["pkg/a.TestRace"].Race)
+	assertions.False(allTests["pkg/a.TestRace"].Flaky)
+}
+
+func TestReadTestDataFromFileRecordsPackageFailures(t *testing.T) {
+	assertions := assert.New(t)
+	// go test -json ./... of a package that does not compile, one that passes and one whose
+	// TestMain fails
+	allPackages, allTests, err := readTestDataFromFile(filepath.Join("testdata", "build_failure.json"), &cmdFlags{}, nil, nil, nil)
+	assertions.Nil(err)
+	assertions.Len(allTests, 1)
+	assertions.Len(allPackages, 3)
+	broken := allPackages["example.com/bf/broken"]
+	assertions.True(broken.Failed)
+	assertions.Equal("example.com/bf/broken [example.com/bf/broken.test]", broken.FailedBuild)
+	assertions.Contains(broken.Output, "broken/broken_test.go:5:33: undefined: undefinedCall\n")
+	mainFail := allPackages["example.com/bf/mainfail"]
+	assertions.True(mainFail.Failed)
+	assertions.Equal("", mainFail.FailedBuild)
+	assertions.Contains(mainFail.Output, "setup failed\n")
+	assertions.False(allPackages["example.com/bf/good"].Failed)
+
+	failures := packageFailures(allTests, allPackages)
+	assertions.Equal([]*packageStatus{broken, mainFail}, failures)
+	summary := newJSONSummary(&templateData{}, allTests, allPackages)
+	assertions.Len(summary.Packages, 3)
+	assertions.Equal("example.com/bf/broken", summary.Packages[0].Name)
+	assertions.True(summary.Packages[0].Failed)
+	assertions.Contains(summary.Packages[0].Output, "undefined: undefinedCall")
+}
+
+func TestFailOnFailedPackages(t *testing.T) {
+	assertions := assert.New(t)
+	statusFile := filepath.Join(t.TempDir(), "status.env")
+	rootCmd, tmplData, _ := initRootCommand()
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+	rootCmd.SetArgs(reportArgs(t, "--input", filepath.Join("testdata", "build_failure.json"), "--env", statusFile, "--fail-on", "failed"))
+	err := rootCmd.Execute()
+	var gateErr *gateError
+	assertions.ErrorAs(err, &gateErr)
+	assertions.EqualError(err, "quality gate failed: fail-on failed (2 failed: package example.com/bf/broken, package example.com/bf/mainfail)")
+	assertions.Len(tmplData.PackageFailures, 2)
+	content, err := os.ReadFile(statusFile)
+	assertions.Nil(err)
+	assertions.Contains(string(content), "export FAILED_PACKAGES=2\nexport PACKAGE_FAILURES='example.com/bf/broken:0 example.com/bf/mainfail:0'\n")
+}
+
+func TestRunDurationIsSharedByTheOutputs(t *testing.T) {
+	assertions := assert.New(t)
+	t.Setenv("START_TIME", "")
+	statusFile := filepath.Join(t.TempDir(), "status.env")
+	rootCmd, tmplData, _ := initRootCommand()
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+	rootCmd.SetArgs(reportArgs(t, "--env", statusFile, "--max-duration", "5ms"))
+	err := rootCmd.Execute()
+	// testdata/simple.json spans 10ms
+	assertions.EqualError(err, "quality gate failed: max-duration 5ms (tests ran for 10ms)")
+	assertions.Equal(10*time.Millisecond, tmplData.TestDuration)
+	content, err := os.ReadFile(statusFile)
+	assertions.Nil(err)
+	assertions.Contains(string(content), "export DURATION=10ms\n")
+}
+
+func TestReportEscapesHostileNames(t *testing.T) {
+	assertions := assert.New(t)
+	allPackages, allTests, err := readTestDataFromFile(filepath.Join("testdata", "hostile_names.json"), &cmdFlags{}, nil, nil, nil)
+	assertions.Nil(err)
+	assertions.Len(allTests, 4)
+	tmplData := &templateData{ReportTitle: `<script>alert("title")</script>`, numOfTestsPerGroup: 20}
+	prepareReport(tmplData, allTests, testFileDetailsByPackage{}, 0)
+	tmplData.Slowest = newSlowestPanel(allTests, allPackages, 10)
+	tmplData.PackageTree = newPackageTree(allTests, allPackages)
+	buffer := &bytes.Buffer{}
+	assertions.Nil(renderHTMLReport(buffer, tmplData))
+	report := buffer.String()
+
+	for _, hostile := range []string{"<img src=x onerror", `"onmouseover=`, "</script><script>", "<svg onload", `<script>alert("title")`} {
+		assertions.NotContains(report, hostile)
+	}
+	assertions.Contains(report, `<meta http-equiv="Content-Security-Policy" content="default-src 'none'; script-src 'nonce-`+tmplData.CSPNonce+`'; style-src 'nonce-`+tmplData.CSPNonce+`';`)
+	assertions.Equal(strings.Count(report, "<script")+strings.Count(report, "<style"), strings.Count(report, `nonce="`+tmplData.CSPNonce+`"`))
+	assertions.NotContains(report, "unsafe-inline")
+	assertions.NotRegexp(`\son[a-z]+=`, string(testReportHTMLTemplateStr))
+	assertions.NotRegexp(`\sstyle=`, report)
+
+	// the outputs are escaped before they are shown as HTML
+	var blocks []string
+	assertions.Nil(json.Unmarshal([]byte(tmplData.OutputBlocks), &blocks))
+	var outputs []string
+	blockLiteral, _ := json.Marshal(blocks[0])
+	gunzipBase64(t, string(blockLiteral), &outputs)
+	for _, output := range outputs {
+		assertions.NotContains(output, "<script")
+		assertions.NotContains(output, "<svg")
+	}
+	assertions.Contains(strings.Join(outputs, ""), `&lt;/pre&gt;&lt;svg onload=alert(1)&gt;`)
+}
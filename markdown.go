@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// defaultMarkdownMaxSize keeps the summary below the 65536 character limit of GitHub comments.
+	defaultMarkdownMaxSize = 65000
+
+	markdownSlowestTests      = 10
+	markdownMaxExcerptLines   = 30
+	markdownTruncationReserve = 256
+)
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// markdownWriter collects markdown blocks up to the size cap. Blocks that do not fit are skipped
+// and counted so that a note about the omitted content can be added at the end; later blocks that
+// fit are still written.
+type markdownWriter struct {
+	builder strings.Builder
+	maxSize int
+	omitted int
+	heading string
+}
+
+// keep writes a block regardless of the size cap, e.g. the title and the totals.
+func (m *markdownWriter) keep(block string) {
+	m.builder.WriteString(block)
+}
+
+// section starts a section whose heading is only written along with its first block that fits.
+func (m *markdownWriter) section(heading string) {
+	m.heading = heading
+}
+
+func (m *markdownWriter) write(block string) {
+	if m.maxSize > 0 && m.builder.Len()+len(m.heading)+len(block) > m.maxSize-markdownTruncationReserve {
+		m.omitted++
+		return
+	}
+	m.builder.WriteString(m.heading)
+	m.builder.WriteString(block)
+	m.heading = ""
+}
+
+func (m *markdownWriter) String() string {
+	if m.omitted == 0 {
+		return m.builder.String()
+	}
+	return m.builder.String() + fmt.Sprintf("\n_The summary was truncated; %d more entries were omitted._\n", m.omitted)
+}
+
+// markdownEscape escapes the characters that would break a markdown table cell or inline code.
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "`", "'").Replace(s)
+}
+
+// markdownExcerpt limits a failure message to markdownMaxExcerptLines lines.
+func markdownExcerpt(message string) string {
+	lines := strings.Split(message, "\n")
+	if len(lines) > markdownMaxExcerptLines {
+		lines = append(lines[:markdownMaxExcerptLines], fmt.Sprintf("... %d more lines", len(lines)-markdownMaxExcerptLines))
+	}
+	return strings.ReplaceAll(strings.Join(lines, "\n"), "```", "'''")
+}
+
+// renderMarkdownSummary renders a compact summary of the test run suitable for pull request
+// comments and CI step summaries. A maxSize of zero disables the size cap.
+func renderMarkdownSummary(summary *jsonSummary, maxSize int) string {
+	md := &markdownWriter{maxSize: maxSize}
+	totals := summary.Totals
+	failedPackages := summary.failedPackages()
+	status := "✅"
+	if totals.Failed > 0 || len(failedPackages) > 0 || newGateError(summary.Gates) != nil {
+		status = "❌"
+	}
+	md.keep(fmt.Sprintf("## %s %s\n\n", status, markdownEscape(summary.Title)))
+	if totals.Known > 0 {
+		md.keep("| Total | Passed | Failed | Known | Skipped | Duration |\n| ---: | ---: | ---: | ---: | ---: | ---: |\n")
+		md.keep(fmt.Sprintf("| %d | %d | %d | %d | %d | %.2fs |\n", totals.Total, totals.Passed, totals.Failed, totals.Known, totals.Skipped, summary.Elapsed))
+	} else {
+		md.keep("| Total | Passed | Failed | Skipped | Duration |\n| ---: | ---: | ---: | ---: | ---: |\n")
+		md.keep(fmt.Sprintf("| %d | %d | %d | %d | %.2fs |\n", totals.Total, totals.Passed, totals.Failed, totals.Skipped, summary.Elapsed))
+	}
+	if len(summary.Gates) > 0 {
+		md.section("\n")
+		for _, gate := range summary.Gates {
+			mark := "✅"
+			if !gate.Passed {
+				mark = "❌"
+			}
+			md.write(fmt.Sprintf("- %s **%s**: %s\n", mark, markdownEscape(gate.Rule), markdownEscape(gate.Message)))
+		}
+	}
+
+	var failed, known, fixed, skipped, slowest []*jsonSummaryTest
+	for _, test := range summary.Tests {
+		switch {
+		case test.isKnownFailure():
+			known = append(known, test)
+		case test.Status == "fail":
+			if len(test.Failures) > 0 || !summary.hasFailedSubtest(test) {
+				failed = append(failed, test)
+			}
+		case test.Status == "skip":
+			skipped = append(skipped, test)
+		case test.KnownFailure != nil:
+			fixed = append(fixed, test)
+		}
+		if test.Elapsed > 0 {
+			slowest = append(slowest, test)
+		}
+	}
+
+	if len(failed) > 0 {
+		md.section(fmt.Sprintf("\n### Failed tests (%d)\n\n", len(failed)))
+		for _, test := range failed {
+			md.write(renderMarkdownFailure(test))
+		}
+	}
+
+	if len(failedPackages) > 0 {
+		md.section(fmt.Sprintf("\n### Failed packages (%d)\n\n", len(failedPackages)))
+		for _, pkg := range failedPackages {
+			md.write(fmt.Sprintf("<details>\n<summary><code>%s</code>: %s</summary>\n\n```\n%s\n```\n\n</details>\n",
+				htmlEscaper.Replace(pkg.Name), htmlEscaper.Replace(pkg.failureText()), markdownExcerpt(strings.TrimRight(pkg.Output, "\n"))))
+		}
+	}
+
+	if len(known) > 0 {
+		md.section(fmt.Sprintf("\n### Known failures (%d)\n\n", len(known)))
+		for _, test := range known {
+			md.write(fmt.Sprintf("- `%s` in `%s`: %s\n", markdownEscape(test.Name), markdownEscape(test.Package), markdownEscape(knownFailureText(test.KnownFailure))))
+		}
+	}
+
+	if len(fixed) > 0 {
+		md.section(fmt.Sprintf("\n### Known failures that passed (%d)\n\nThese tests can be removed from the known failures file.\n\n", len(fixed)))
+		for _, test := range fixed {
+			md.write(fmt.Sprintf("- `%s` in `%s`\n", markdownEscape(test.Name), markdownEscape(test.Package)))
+		}
+	}
+
+	if len(slowest) > 0 {
+		sort.SliceStable(slowest, func(i, j int) bool {
+			return slowest[i].Elapsed > slowest[j].Elapsed
+		})
+		if len(slowest) > markdownSlowestTests {
+			slowest = slowest[:markdownSlowestTests]
+		}
+		md.section("\n### Slowest tests\n\n| Test | Package | Duration |\n| --- | --- | ---: |\n")
+		for _, test := range slowest {
+			md.write(fmt.Sprintf("| `%s` | `%s` | %.2fs |\n", markdownEscape(test.Name), markdownEscape(test.Package), test.Elapsed))
+		}
+	}
+
+	if len(skipped) > 0 {
+		var reasons []string
+		byReason := map[string][]*jsonSummaryTest{}
+		for _, test := range skipped {
+			reason := test.SkipReason
+			if reason == "" {
+				reason = "no reason given"
+			}
+			if _, exists := byReason[reason]; !exists {
+				reasons = append(reasons, reason)
+			}
+			byReason[reason] = append(byReason[reason], test)
+		}
+		sort.Strings(reasons)
+		md.section(fmt.Sprintf("\n### Skipped tests (%d)\n\n", len(skipped)))
+		for _, reason := range reasons {
+			names := make([]string, 0, len(byReason[reason]))
+			for _, test := range byReason[reason] {
+				names = append(names, fmt.Sprintf("`%s`", markdownEscape(test.Name)))
+			}
+			md.write(fmt.Sprintf("- **%s** (%d): %s\n", markdownEscape(reason), len(names), strings.Join(names, ", ")))
+		}
+	}
+	return md.String()
+}
+
+func renderMarkdownFailure(test *jsonSummaryTest) string {
+	location := ""
+	if len(test.Failures) > 0 {
+		location = fmt.Sprintf(" at <code>%s:%d</code>", htmlEscaper.Replace(test.Failures[0].File), test.Failures[0].Line)
+	} else if test.Location != nil {
+		location = fmt.Sprintf(" at <code>%s:%d</code>", htmlEscaper.Replace(test.Location.File), test.Location.Line)
+	}
+	block := fmt.Sprintf("<details>\n<summary><code>%s</code> in <code>%s</code>%s</summary>\n\n",
+		htmlEscaper.Replace(test.Name), htmlEscaper.Replace(test.Package), location)
+	if len(test.Failures) == 0 {
+		return block + "No failure message was logged.\n\n</details>\n"
+	}
+	block += "```\n"
+	for _, failure := range test.Failures {
+		block += fmt.Sprintf("%s:%d: %s\n", failure.File, failure.Line, markdownExcerpt(failure.Message))
+	}
+	return block + "```\n\n</details>\n"
+}
+
+// appendMarkdownSummary appends the summary to a file such as the one named by $GITHUB_STEP_SUMMARY.
+func appendMarkdownSummary(filename string, markdown string) error {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, markdown); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func markdownTestSummary() *jsonSummary {
+	return &jsonSummary{
+		Title:   "test-title",
+		Elapsed: 2,
+		Totals:  jsonSummaryCounts{Total: 5, Passed: 1, Failed: 2, Skipped: 2},
+		Tests: []*jsonSummaryTest{
+			{Name: "TestA", Package: "pkg/a", Status: "fail"},
+			{Name: "TestA/sub|pipe", Package: "pkg/a", Status: "fail", Elapsed: 0.5,
+				Failures: []failureMessage{{File: "a_test.go", Line: 12, Message: "expected <1>"}}},
+			{Name: "TestB", Package: "pkg/a", Status: "pass", Elapsed: 1.5},
+			{Name: "TestC", Package: "pkg/b", Status: "skip", SkipReason: "needs docker"},
+			{Name: "TestD", Package: "pkg/b", Status: "skip", SkipReason: "needs docker"},
+		},
+	}
+}
+
+func TestRenderMarkdownSummary(t *testing.T) {
+	assertions := assert.New(t)
+	markdown := renderMarkdownSummary(markdownTestSummary(), 0)
+	assertions.Contains(markdown, "## ❌ test-title")
+	assertions.Contains(markdown, "| 5 | 1 | 2 | 2 | 2.00s |")
+	assertions.Contains(markdown, "### Failed tests (1)")
+	assertions.Contains(markdown, "<summary><code>TestA/sub|pipe</code> in <code>pkg/a</code> at <code>a_test.go:12</code></summary>")
+	assertions.Contains(markdown, "a_test.go:12: expected <1>\n")
+	assertions.NotContains(markdown, "<code>TestA</code>")
+	assertions.Contains(markdown, "| `TestB` | `pkg/a` | 1.50s |\n| `TestA/sub\\|pipe` | `pkg/a` | 0.50s |")
+	assertions.Contains(markdown, "- **needs docker** (2): `TestC`, `TestD`")
+	assertions.NotContains(markdown, "truncated")
+}
+
+func TestRenderMarkdownSummaryPackageFailures(t *testing.T) {
+	assertions := assert.New(t)
+	markdown := renderMarkdownSummary(buildFailureSummary(t), 0)
+	assertions.Contains(markdown, "## ❌ ")
+	assertions.Contains(markdown, "### Failed packages (2)")
+	assertions.Contains(markdown, "<summary><code>example.com/bf/broken</code>: build failed: example.com/bf/broken [example.com/bf/broken.test]</summary>\n\n```\n# example.com/bf/broken [example.com/bf/broken.test]\nbroken/broken_test.go:5:33: undefined: undefinedCall\nFAIL\texample.com/bf/broken [build failed]\n```")
+	assertions.Contains(markdown, "<summary><code>example.com/bf/mainfail</code>: package failed outside of its tests</summary>")
+}
+
+func TestRenderMarkdownSummaryWithSizeCap(t *testing.T) {
+	assertions := assert.New(t)
+	summary := markdownTestSummary()
+	for i := 0; i < 100; i++ {
+		summary.Tests = append(summary.Tests, &jsonSummaryTest{Name: "TestMany", Package: "pkg/c", Status: "fail",
+			Failures: []failureMessage{{File: "c_test.go", Line: i, Message: strings.Repeat("x", 100)}}})
+	}
+	markdown := renderMarkdownSummary(summary, 2000)
+	assertions.LessOrEqual(len(markdown), 2000)
+	assertions.Contains(markdown, "| 5 | 1 | 2 | 2 | 2.00s |")
+	assertions.Contains(markdown, "more entries were omitted")
+
+	// a failure that does not fit is skipped, not the blocks after it
+	summary = markdownTestSummary()
+	summary.Tests[1].Failures[0].Message = strings.Repeat("x", 2000)
+	markdown = renderMarkdownSummary(summary, 2000)
+	assertions.NotContains(markdown, "### Failed tests")
+	assertions.Contains(markdown, "| `TestB` | `pkg/a` | 1.50s |")
+	assertions.Contains(markdown, "- **needs docker** (2): `TestC`, `TestD`")
+	assertions.Contains(markdown, "1 more entries were omitted")
+
+	// the title and the totals are kept even if nothing else fits
+	markdown = renderMarkdownSummary(summary, 100)
+	assertions.True(strings.HasPrefix(markdown, "## ❌ test-title\n\n| Total |"), markdown)
+	assertions.Contains(markdown, "| 5 | 1 | 2 | 2 | 2.00s |")
+	assertions.NotContains(markdown, "###")
+}
+
+func TestAppendMarkdownSummary(t *testing.T) {
+	assertions := assert.New(t)
+	filename := filepath.Join(t.TempDir(), "step_summary.md")
+	assertions.Nil(appendMarkdownSummary(filename, "first\n"))
+	assertions.Nil(appendMarkdownSummary(filename, "second\n"))
+	content, err := os.ReadFile(filename)
+	assertions.Nil(err)
+	assertions.Equal("first\nsecond\n", string(content))
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The types of the run metadata shown in the report header.
+const (
+	infoText      = "text"
+	infoLink      = "link"
+	infoTimestamp = "timestamp"
+)
+
+// ciProvider describes how to read the build link and the job name of a CI service from its
+// environment variables.
+type ciProvider struct {
+	name     string
+	detect   string
+	buildURL func() string
+	job      string
+}
+
+var ciProviders = []ciProvider{
+	{"GitHub Actions", "GITHUB_ACTIONS", func() string {
+		return joinEnv("GITHUB_SERVER_URL", "/", "GITHUB_REPOSITORY", "/actions/runs/", "GITHUB_RUN_ID")
+	}, "GITHUB_JOB"},
+	{"GitLab CI", "GITLAB_CI", envValue("CI_JOB_URL"), "CI_JOB_NAME"},
+	{"Jenkins", "JENKINS_URL", envValue("BUILD_URL"), "JOB_NAME"},
+	{"CircleCI", "CIRCLECI", envValue("CIRCLE_BUILD_URL"), "CIRCLE_JOB"},
+	{"Buildkite", "BUILDKITE", envValue("BUILDKITE_BUILD_URL"), "BUILDKITE_LABEL"},
+	{"Travis CI", "TRAVIS", envValue("TRAVIS_JOB_WEB_URL"), "TRAVIS_JOB_NAME"},
+	{"Azure Pipelines", "TF_BUILD", func() string {
+		return joinEnv("SYSTEM_COLLECTIONURI", "/", "SYSTEM_TEAMPROJECT", "/_build/results?buildId=", "BUILD_BUILDID")
+	}, "SYSTEM_JOBDISPLAYNAME"},
+}
+
+// branchVariables are the environment variables of the CI services holding the branch under test,
+// which git does not know when the commit is checked out detached.
+var branchVariables = []string{"GITHUB_HEAD_REF", "GITHUB_REF_NAME", "CI_COMMIT_REF_NAME", "BRANCH_NAME",
+	"CIRCLE_BRANCH", "BUILDKITE_BRANCH", "TRAVIS_BRANCH", "BUILD_SOURCEBRANCHNAME"}
+
+func envValue(name string) func() string {
+	return func() string {
+		return os.Getenv(name)
+	}
+}
+
+// joinEnv joins the values of the variables with the separators in between, e.g. "HOST", "/",
+// "PATH". It returns an empty string if a variable is not set.
+func joinEnv(parts ...string) string {
+	var b strings.Builder
+	for i, part := range parts {
+		if i%2 == 1 {
+			b.WriteString(part)
+			continue
+		}
+		value := os.Getenv(part)
+		if value == "" {
+			return ""
+		}
+		b.WriteString(strings.TrimSuffix(value, "/"))
+	}
+	return b.String()
+}
+
+// newInfo returns a metadata entry of the given type, which defaults to text. Links must be http or
+// https URLs, and timestamps RFC 3339 times.
+func newInfo(key, value, infoType string) (Info, error) {
+	info := Info{Key: key, Value: value, Type: infoType}
+	switch infoType {
+	case "", infoText:
+		info.Type = infoText
+	case infoLink:
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return info, fmt.Errorf("%s: %q is not an http or https link", key, value)
+		}
+		info.IsLink = true
+	case infoTimestamp:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return info, fmt.Errorf("%s: %q is not an RFC 3339 timestamp", key, value)
+		}
+		info.Value = t.Format(time.RFC3339)
+	default:
+		return info, fmt.Errorf("%s: unknown type %q; expected one of %s, %s, %s", key, infoType, infoText, infoLink, infoTimestamp)
+	}
+	return info, nil
+}
+
+// parseServerInfo parses the --serverInfo flag, a list of key::value entries separated by
+// semicolons. A value may contain "::", and "\;" for a semicolon; http and https URLs are links.
+func parseServerInfo(serverInfo string) []Info {
+	var infos []Info
+	for _, entry := range strings.Split(strings.ReplaceAll(serverInfo, `\;`, "\x00"), ";") {
+		kv := strings.SplitN(strings.ReplaceAll(entry, "\x00", ";"), "::", 2)
+		if len(kv) < 2 || kv[0] == "" {
+			continue
+		}
+		info, err := newInfo(kv[0], kv[1], infoLink)
+		if err != nil {
+			info, _ = newInfo(kv[0], kv[1], infoText)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// readMetadataFile reads a --metadata file: a YAML or JSON list of entries with a key, a value
+// and an optional type, one of text, link or timestamp.
+func readMetadataFile(filename string) ([]Info, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Info
+	if err := yaml.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("invalid metadata file %s: %w", filename, err)
+	}
+	infos := make([]Info, 0, len(entries))
+	for i, entry := range entries {
+		if entry.Key == "" {
+			return nil, fmt.Errorf("invalid metadata file %s: entry %d has no key", filename, i+1)
+		}
+		info, err := newInfo(entry.Key, entry.Value, entry.Type)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata file %s: %w", filename, err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// gitOutput returns the trimmed output of a git command and whether it succeeded; it fails e.g.
+// outside a repository.
+func gitOutput(args ...string) (string, bool) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(out)), true
+}
+
+// branchName returns the branch under test, taken from the CI environment or from git.
+func branchName() string {
+	for _, name := range branchVariables {
+		if branch := os.Getenv(name); branch != "" {
+			return branch
+		}
+	}
+	if branch, _ := gitOutput("rev-parse", "--abbrev-ref", "HEAD"); branch != "HEAD" {
+		return branch
+	}
+	return ""
+}
+
+// collectMetadata returns the metadata of the run that is found automatically: the commit, branch
+// and working tree state from git, the Go version and platform, the host name and the build and
+// job of well-known CI services. Whatever cannot be found is left out.
+func collectMetadata() []Info {
+	var infos []Info
+	add := func(key, value, infoType string) {
+		if value == "" {
+			return
+		}
+		if info, err := newInfo(key, value, infoType); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	add("Commit", commitID(), infoText)
+	add("Branch", branchName(), infoText)
+	if status, ok := gitOutput("status", "--porcelain"); ok {
+		state := "clean"
+		if status != "" {
+			state = "dirty"
+		}
+		add("Working tree", state, infoText)
+	}
+	if out, err := exec.Command("go", "env", "GOVERSION", "GOOS", "GOARCH").Output(); err == nil {
+		if env := strings.Fields(string(out)); len(env) == 3 {
+			add("Go", env[0], infoText)
+			add("Platform", env[1]+"/"+env[2], infoText)
+		}
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		add("Host", hostname, infoText)
+	}
+	for _, ci := range ciProviders {
+		if os.Getenv(ci.detect) == "" {
+			continue
+		}
+		add("CI", ci.name, infoText)
+		add("Build", ci.buildURL(), infoLink)
+		add("Job", os.Getenv(ci.job), infoText)
+		break
+	}
+	return infos
+}
+
+// mergeMetadata joins lists of metadata entries. An entry is left out if an earlier one has the
+// same key, so that given entries replace the ones found automatically.
+func mergeMetadata(lists ...[]Info) []Info {
+	infos := []Info{}
+	keys := map[string]bool{}
+	for _, list := range lists {
+		for _, info := range list {
+			if key := strings.ToLower(info.Key); !keys[key] {
+				keys[key] = true
+				infos = append(infos, info)
+			}
+		}
+	}
+	return infos
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewInfo(t *testing.T) {
+	assertions := assert.New(t)
+	info, err := newInfo("Release", "v1", "")
+	assertions.Nil(err)
+	assertions.Equal(Info{Key: "Release", Value: "v1", Type: infoText}, info)
+	info, err = newInfo("Build", "https://ci.example.com/1", infoLink)
+	assertions.Nil(err)
+	assertions.Equal(Info{Key: "Build", Value: "https://ci.example.com/1", Type: infoLink, IsLink: true}, info)
+	info, err = newInfo("Deployed", "2026-10-19T08:00:00.5Z", infoTimestamp)
+	assertions.Nil(err)
+	assertions.Equal("2026-10-19T08:00:00Z", info.Value)
+
+	_, err = newInfo("Build", "javascript:alert(1)", infoLink)
+	assertions.EqualError(err, `Build: "javascript:alert(1)" is not an http or https link`)
+	_, err = newInfo("Deployed", "yesterday", infoTimestamp)
+	assertions.EqualError(err, `Deployed: "yesterday" is not an RFC 3339 timestamp`)
+	_, err = newInfo("Size", "1", "number")
+	assertions.EqualError(err, `Size: unknown type "number"; expected one of text, link, timestamp`)
+}
+
+func TestParseServerInfo(t *testing.T) {
+	assertions := assert.New(t)
+	assertions.Equal([]Info{
+		{Key: "Server", Value: "https://staging.example.com/a::b", Type: infoLink, IsLink: true},
+		{Key: "Note", Value: "see http docs; a::b", Type: infoText},
+	}, parseServerInfo(`Server::https://staging.example.com/a::b;Note::see http docs\; a::b;broken;`))
+	assertions.Nil(parseServerInfo(""))
+}
+
+func TestReadMetadataFile(t *testing.T) {
+	assertions := assert.New(t)
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "metadata.yaml")
+	assertions.Nil(os.WriteFile(yamlFile, []byte("- key: Release\n  value: v1.2\n- key: Deployed\n  value: 2026-10-19T10:00:00+02:00\n  type: timestamp\n"), 0644))
+	infos, err := readMetadataFile(yamlFile)
+	assertions.Nil(err)
+	assertions.Equal([]Info{
+		{Key: "Release", Value: "v1.2", Type: infoText},
+		{Key: "Deployed", Value: "2026-10-19T10:00:00+02:00", Type: infoTimestamp},
+	}, infos)
+
+	jsonFile := filepath.Join(dir, "metadata.json")
+	assertions.Nil(os.WriteFile(jsonFile, []byte(`[{"key": "Dashboard", "value": "https://grafana.example.com/d/1", "type": "link"}]`), 0644))
+	infos, err = readMetadataFile(jsonFile)
+	assertions.Nil(err)
+	assertions.Equal([]Info{{Key: "Dashboard", Value: "https://grafana.example.com/d/1", Type: infoLink, IsLink: true}}, infos)
+
+	assertions.Nil(os.WriteFile(jsonFile, []byte(`[{"value": "v1"}]`), 0644))
+	_, err = readMetadataFile(jsonFile)
+	assertions.EqualError(err, "invalid metadata file "+jsonFile+": entry 1 has no key")
+	assertions.Nil(os.WriteFile(jsonFile, []byte(`[{"key": "Dashboard", "value": "grafana", "type": "link"}]`), 0644))
+	_, err = readMetadataFile(jsonFile)
+	assertions.EqualError(err, "invalid metadata file "+jsonFile+`: Dashboard: "grafana" is not an http or https link`)
+}
+
+func TestCollectMetadata(t *testing.T) {
+	assertions := assert.New(t)
+	for name, value := range map[string]string{
+		"GITHUB_ACTIONS":    "true",
+		"GITHUB_SERVER_URL": "https://github.com",
+		"GITHUB_REPOSITORY": "octo/repo",
+		"GITHUB_RUN_ID":     "42",
+		"GITHUB_JOB":        "test",
+		"GITHUB_SHA":        "0123abc",
+		"GITHUB_HEAD_REF":   "feature/x",
+	} {
+		t.Setenv(name, value)
+	}
+	infos := map[string]Info{}
+	for _, info := range collectMetadata() {
+		infos[info.Key] = info
+	}
+	assertions.Equal("0123abc", infos["Commit"].Value)
+	assertions.Equal("feature/x", infos["Branch"].Value)
+	assertions.Equal("GitHub Actions", infos["CI"].Value)
+	assertions.Equal(Info{Key: "Build", Value: "https://github.com/octo/repo/actions/runs/42", Type: infoLink, IsLink: true}, infos["Build"])
+	assertions.Equal("test", infos["Job"].Value)
+	assertions.Regexp(`^go\d`, infos["Go"].Value)
+	assertions.Regexp(`^\w+/\w+$`, infos["Platform"].Value)
+	assertions.NotEmpty(infos["Host"].Value)
+}
+
+func TestAutoMetadataIsOptIn(t *testing.T) {
+	assertions := assert.New(t)
+	t.Setenv("GITHUB_ACTIONS", "true")
+	rootCmd, tmplData, _ := initRootCommand()
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetArgs(reportArgs(t))
+	assertions.Nil(rootCmd.Execute())
+	assertions.Empty(tmplData.ServerInfo)
+
+	rootCmd, tmplData, _ = initRootCommand()
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetArgs(reportArgs(t, "--auto-metadata"))
+	assertions.Nil(rootCmd.Execute())
+	keys := map[string]bool{}
+	for _, info := range tmplData.ServerInfo {
+		keys[info.Key] = true
+	}
+	assertions.True(keys["Host"])
+	assertions.True(keys["CI"])
+}
+
+func TestMergeMetadata(t *testing.T) {
+	assertions := assert.New(t)
+	given := []Info{{Key: "Branch", Value: "release", Type: infoText}}
+	found := []Info{{Key: "branch", Value: "HEAD", Type: infoText}, {Key: "Host", Value: "ci-1", Type: infoText}}
+	assertions.Equal([]Info{given[0], found[1]}, mergeMetadata(given, nil, found))
+	assertions.Equal([]Info{}, mergeMetadata())
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// truncatedMarker replaces the middle of an output that exceeds the limits.
+const truncatedMarker = "\n... %d bytes truncated ...\n"
+
+type (
+	// outputLimiter applies --max-output-per-test and --max-output-total while the outputs of the
+	// tests are read. A test keeps the beginning and the end of its output, up to half of the
+	// limit each; once the total is reached, the outputs do not grow any further.
+	outputLimiter struct {
+		perTest int
+		total   int
+		kept    int
+		outputs map[*testStatus]*limitedOutput
+
+		// fullOutputDir is where the full outputs of truncated tests are written, if not empty, and
+		// fullOutputLink the path of the directory relative to the report.
+		fullOutputDir  string
+		fullOutputLink string
+	}
+
+	// limitedOutput is the end of the output of a test once its beginning is complete.
+	limitedOutput struct {
+		headBytes  int
+		truncating bool
+		tail       []string
+		tailBytes  int
+		truncated  int
+
+		// fullOutputPath is the full output file once the output is truncated, which is kept open
+		// as fullOutput until the test ends.
+		fullOutputPath string
+		fullOutput     *os.File
+	}
+)
+
+// parseByteSize parses a size like 1048576, 512KB or 10MB; the units are powers of 1024.
+func parseByteSize(size string) (int, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := 1
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, multiplier = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q; expected a number of bytes, e.g. 1048576, 512KB or 10MB", size)
+	}
+	return n * multiplier, nil
+}
+
+// newOutputLimiter returns the limiter of the --max-output-per-test and --max-output-total flags,
+// or nil if neither is set. With --full-output, the full outputs of truncated tests are written
+// next to the HTML report written to reportPath.
+func newOutputLimiter(flags *cmdFlags, reportPath string) (*outputLimiter, error) {
+	l := &outputLimiter{outputs: map[*testStatus]*limitedOutput{}}
+	var err error
+	if flags.maxOutputPerTestFlag != "" {
+		if l.perTest, err = parseByteSize(flags.maxOutputPerTestFlag); err != nil {
+			return nil, fmt.Errorf("--max-output-per-test: %w", err)
+		}
+	}
+	if flags.maxOutputTotalFlag != "" {
+		if l.total, err = parseByteSize(flags.maxOutputTotalFlag); err != nil {
+			return nil, fmt.Errorf("--max-output-total: %w", err)
+		}
+	}
+	if l.perTest == 0 && l.total == 0 {
+		return nil, nil
+	}
+	if flags.fullOutputFlag {
+		if reportPath == "" {
+			return nil, errors.New("--full-output needs an HTML report to link the outputs from")
+		}
+		// e.g. report_outputs next to report.html
+		l.fullOutputLink = strings.TrimSuffix(filepath.Base(reportPath), filepath.Ext(reportPath)) + "_outputs"
+		l.fullOutputDir = filepath.Join(filepath.Dir(reportPath), l.fullOutputLink)
+	}
+	return l, nil
+}
+
+// cutAt returns the position at or before n that does not split a UTF-8 character of s.
+func cutAt(s string, n int) int {
+	for n > 0 && n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return n
+}
+
+// append adds a chunk of output to the test. Without limits, it is just appended.
+func (l *outputLimiter) append(status *testStatus, chunk string) error {
+	if l == nil {
+		status.Output = append(status.Output, chunk)
+		return nil
+	}
+	o := l.outputs[status]
+	if o == nil {
+		o = &limitedOutput{}
+		l.outputs[status] = o
+	}
+	if o.fullOutputPath != "" && chunk != "" {
+		if err := l.writeFullOutput(o, chunk); err != nil {
+			return err
+		}
+	}
+	rest := chunk
+	if !o.truncating {
+		room := len(chunk)
+		if l.perTest > 0 && l.perTest/2-o.headBytes < room {
+			room = l.perTest/2 - o.headBytes
+		}
+		if l.total > 0 && l.total-l.kept < room {
+			room = l.total - l.kept
+		}
+		room = cutAt(chunk, room)
+		if room > 0 {
+			status.Output = append(status.Output, chunk[:room])
+			o.headBytes += room
+			l.kept += room
+		}
+		if room == len(chunk) {
+			return nil
+		}
+		o.truncating = true
+		rest = chunk[room:]
+	}
+
+	// the tail keeps the last half of the per test limit, without growing past the total
+	capacity := l.perTest - l.perTest/2
+	if l.total > 0 && o.tailBytes+l.total-l.kept < capacity {
+		capacity = o.tailBytes + l.total - l.kept
+	}
+	if capacity < 0 {
+		capacity = 0
+	}
+	o.tail = append(o.tail, rest)
+	o.tailBytes += len(rest)
+	l.kept += len(rest)
+	for o.tailBytes > capacity {
+		if o.fullOutputPath == "" && l.fullOutputDir != "" {
+			if err := l.startFullOutput(status, o); err != nil {
+				return err
+			}
+		}
+		first := o.tail[0]
+		drop := len(first)
+		if excess := o.tailBytes - capacity; excess < drop {
+			// drop whole characters, even if that leaves less than the capacity
+			drop = excess
+			for drop < len(first) && !utf8.RuneStart(first[drop]) {
+				drop++
+			}
+		}
+		o.truncated += drop
+		o.tailBytes -= drop
+		l.kept -= drop
+		if drop == len(first) {
+			o.tail = o.tail[1:]
+		} else {
+			o.tail[0] = first[drop:]
+		}
+	}
+	return nil
+}
+
+// startFullOutput writes the output of the test read so far to its full output file, to which
+// the rest of the output is added as it is read.
+func (l *outputLimiter) startFullOutput(status *testStatus, o *limitedOutput) error {
+	if err := os.MkdirAll(l.fullOutputDir, 0755); err != nil {
+		return err
+	}
+	o.fullOutputPath = filepath.Join(l.fullOutputDir, testID(status.Package, status.TestName)+".log")
+	if err := os.WriteFile(o.fullOutputPath, nil, 0644); err != nil {
+		return err
+	}
+	for _, chunk := range append(append([]string{}, status.Output...), o.tail...) {
+		if err := l.writeFullOutput(o, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFullOutput appends a chunk of output to the full output file, which is opened again if
+// output follows the end of the test.
+func (l *outputLimiter) writeFullOutput(o *limitedOutput, chunk string) error {
+	if o.fullOutput == nil {
+		f, err := os.OpenFile(o.fullOutputPath, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		o.fullOutput = f
+	}
+	_, err := o.fullOutput.WriteString(chunk)
+	return err
+}
+
+// end closes the full output file of the test, if open, so that the files of a large run are not
+// all open at once.
+func (l *outputLimiter) end(status *testStatus) error {
+	if l == nil || l.outputs[status] == nil || l.outputs[status].fullOutput == nil {
+		return nil
+	}
+	o := l.outputs[status]
+	err := o.fullOutput.Close()
+	o.fullOutput = nil
+	return err
+}
+
+// finish adds the truncation markers and the ends of the truncated outputs to the tests and
+// closes the full output files.
+func (l *outputLimiter) finish() error {
+	if l == nil {
+		return nil
+	}
+	var err error
+	for status, o := range l.outputs {
+		if o.truncated > 0 {
+			status.Output = append(status.Output, fmt.Sprintf(truncatedMarker, o.truncated))
+			status.TruncatedBytes = o.truncated
+		}
+		status.Output = append(status.Output, o.tail...)
+		if closeErr := l.end(status); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		if o.fullOutputPath != "" {
+			status.FullOutput = l.fullOutputLink + "/" + testID(status.Package, status.TestName) + ".log"
+		}
+	}
+	return err
+}
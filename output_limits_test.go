@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseByteSize(t *testing.T) {
+	assertions := assert.New(t)
+	for size, expected := range map[string]int{"0": 0, "1048576": 1048576, "512KB": 512 << 10, "10mb": 10 << 20, "1 GB": 1 << 30, "64B": 64} {
+		n, err := parseByteSize(size)
+		assertions.Nil(err, size)
+		assertions.Equal(expected, n, size)
+	}
+	for _, size := range []string{"", "-1", "1.5MB", "10TB", "MB"} {
+		_, err := parseByteSize(size)
+		assertions.Error(err, size)
+	}
+}
+
+func TestNewOutputLimiter(t *testing.T) {
+	assertions := assert.New(t)
+	l, err := newOutputLimiter(&cmdFlags{}, "report.html")
+	assertions.Nil(err)
+	assertions.Nil(l)
+
+	l, err = newOutputLimiter(&cmdFlags{maxOutputPerTestFlag: "1KB", fullOutputFlag: true}, filepath.Join("out", "report.html"))
+	assertions.Nil(err)
+	assertions.Equal(1024, l.perTest)
+	assertions.Equal(filepath.Join("out", "report_outputs"), l.fullOutputDir)
+	assertions.Equal("report_outputs", l.fullOutputLink)
+
+	_, err = newOutputLimiter(&cmdFlags{maxOutputTotalFlag: "lots"}, "report.html")
+	assertions.EqualError(err, `--max-output-total: invalid size "lots"; expected a number of bytes, e.g. 1048576, 512KB or 10MB`)
+	_, err = newOutputLimiter(&cmdFlags{maxOutputPerTestFlag: "1KB", fullOutputFlag: true}, "")
+	assertions.EqualError(err, "--full-output needs an HTML report to link the outputs from")
+}
+
+func TestOutputLimiterPerTest(t *testing.T) {
+	assertions := assert.New(t)
+	l := &outputLimiter{perTest: 10, outputs: map[*testStatus]*limitedOutput{}}
+	short, long := &testStatus{}, &testStatus{}
+	for _, chunk := range []string{"abc\n", "defgh\n", "ijk\n", "lmnop\n"} {
+		assertions.Nil(l.append(long, chunk))
+	}
+	assertions.Nil(l.append(short, "ok\n"))
+	assertions.Nil(l.finish())
+	assertions.Equal("abc\nd\n... 10 bytes truncated ...\nmnop\n", strings.Join(long.Output, ""))
+	assertions.Equal(10, long.TruncatedBytes)
+	assertions.Equal([]string{"ok\n"}, short.Output)
+	assertions.Equal(0, short.TruncatedBytes)
+
+	// characters are never split
+	l = &outputLimiter{perTest: 3, outputs: map[*testStatus]*limitedOutput{}}
+	status := &testStatus{}
+	assertions.Nil(l.append(status, "ééé"))
+	assertions.Nil(l.finish())
+	assertions.Equal("\n... 4 bytes truncated ...\né", strings.Join(status.Output, ""))
+}
+
+func TestOutputLimiterTotal(t *testing.T) {
+	assertions := assert.New(t)
+	l := &outputLimiter{total: 8, outputs: map[*testStatus]*limitedOutput{}}
+	first, second := &testStatus{}, &testStatus{}
+	assertions.Nil(l.append(first, "12345\n"))
+	assertions.Nil(l.append(second, "abcdef\n"))
+	assertions.Nil(l.append(first, "more\n"))
+	assertions.Nil(l.finish())
+	assertions.Equal("12345\n\n... 5 bytes truncated ...\n", strings.Join(first.Output, ""))
+	assertions.Equal(5, first.TruncatedBytes)
+	assertions.Equal("ab\n... 5 bytes truncated ...\n", strings.Join(second.Output, ""))
+	assertions.Equal(8, l.kept)
+}
+
+func TestOutputLimiterFullOutput(t *testing.T) {
+	assertions := assert.New(t)
+	dir := t.TempDir()
+	l, err := newOutputLimiter(&cmdFlags{maxOutputPerTestFlag: "8", fullOutputFlag: true}, filepath.Join(dir, "report.html"))
+	assertions.Nil(err)
+	status := &testStatus{Package: "pkg", TestName: "TestA"}
+	for _, chunk := range []string{"start\n", "dial db:5432\n", "done\n"} {
+		assertions.Nil(l.append(status, chunk))
+	}
+	// output written after the end of the test is added as well
+	assertions.Nil(l.end(status))
+	assertions.Nil(l.append(status, "late\n"))
+	short := &testStatus{Package: "pkg", TestName: "TestB"}
+	assertions.Nil(l.append(short, "ok\n"))
+	assertions.Nil(l.finish())
+
+	id := testID("pkg", "TestA")
+	assertions.Equal("report_outputs/"+id+".log", status.FullOutput)
+	content, err := os.ReadFile(filepath.Join(dir, "report_outputs", id+".log"))
+	assertions.Nil(err)
+	assertions.Equal("start\ndial db:5432\ndone\nlate\n", string(content))
+
+	// tests within the limit have no full output file
+	assertions.Equal("", short.FullOutput)
+	_, err = os.Stat(filepath.Join(dir, "report_outputs", testID("pkg", "TestB")+".log"))
+	assertions.True(os.IsNotExist(err))
+}
+
+func TestReadTestDataFromFileRedactsBeforeTruncating(t *testing.T) {
+	assertions := assert.New(t)
+	dir := t.TempDir()
+	data := `{"Action":"run","Package":"pkg","Test":"TestLoginHeader"}
+{"Action":"output","Package":"pkg","Test":"TestLoginHeader","Output":"=== RUN   TestLoginHeader\n"}
+{"Action":"output","Package":"pkg","Test":"TestLoginHeader","Output":"    login_test.go:9: Bearer SECRETTOKEN1234567890abcdef\n"}
+{"Action":"output","Package":"pkg","Test":"TestLoginHeader","Output":"    login_test.go:10: unauthorized\n"}
+{"Action":"output","Package":"pkg","Test":"TestLoginHeader","Output":"--- FAIL: TestLoginHeader (0.00s)\n"}
+{"Action":"fail","Package":"pkg","Test":"TestLoginHeader","Elapsed":0}
+`
+	inputFile := filepath.Join(dir, "input.json")
+	assertions.Nil(os.WriteFile(inputFile, []byte(data), 0644))
+	r, err := newRedactor(&cmdFlags{})
+	assertions.Nil(err)
+	l, err := newOutputLimiter(&cmdFlags{maxOutputPerTestFlag: "100", fullOutputFlag: true}, filepath.Join(dir, "report.html"))
+	assertions.Nil(err)
+	_, allTests, err := readTestDataFromFile(inputFile, &cmdFlags{}, nil, r, l)
+	assertions.Nil(err)
+
+	// the token straddles the end of the kept beginning of the output
+	status := allTests["pkg.TestLoginHeader"]
+	assertions.Equal("=== RUN   TestLoginHeader\n    login_test.go:9: Bea\n... 34 bytes truncated ...\n0: unauthorized\n--- FAIL: TestLoginHeader (0.00s)\n",
+		strings.Join(status.Output, ""))
+	assertions.Equal(1, r.count)
+	content, err := os.ReadFile(filepath.Join(dir, "report_outputs", testID("pkg", "TestLoginHeader")+".log"))
+	assertions.Nil(err)
+	assertions.Contains(string(content), "    login_test.go:9: Bearer [REDACTED]\n")
+	assertions.NotContains(string(content), "SECRETTOKEN")
+}
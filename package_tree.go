@@ -0,0 +1,107 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// packageNode is a node of the package hierarchy shown in the sidebar of the report. Its counts
+// and duration include the tests of all packages below it.
+type packageNode struct {
+	Name               string
+	Path               string
+	IsPackage          bool
+	NumOfTestPassed    int
+	NumOfTestFailed    int
+	NumOfTestSkipped   int
+	NumOfKnownFailures int
+	ElapsedTime        float64
+	Children           []*packageNode
+}
+
+// NumOfTests returns the number of tests of the node.
+func (n *packageNode) NumOfTests() int {
+	return n.NumOfTestPassed + n.NumOfTestFailed + n.NumOfTestSkipped + n.NumOfKnownFailures
+}
+
+func (n *packageNode) child(name string) *packageNode {
+	for _, child := range n.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	path := name
+	if n.Path != "" {
+		path = n.Path + "/" + name
+	}
+	child := &packageNode{Name: name, Path: path}
+	n.Children = append(n.Children, child)
+	return child
+}
+
+// sum adds the counts and durations of the children to the node and sorts the children by name.
+func (n *packageNode) sum() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+	for _, child := range n.Children {
+		child.sum()
+		n.NumOfTestPassed += child.NumOfTestPassed
+		n.NumOfTestFailed += child.NumOfTestFailed
+		n.NumOfTestSkipped += child.NumOfTestSkipped
+		n.NumOfKnownFailures += child.NumOfKnownFailures
+		n.ElapsedTime += child.ElapsedTime
+	}
+}
+
+// compact merges every node that is not a package and has a single child with that child, so
+// that e.g. github.com/org/repo is one node.
+func (n *packageNode) compact() {
+	for i, child := range n.Children {
+		for !child.IsPackage && len(child.Children) == 1 {
+			grandchild := child.Children[0]
+			grandchild.Name = child.Name + "/" + grandchild.Name
+			child = grandchild
+		}
+		n.Children[i] = child
+		child.compact()
+	}
+}
+
+// newPackageTree returns the package hierarchy of the tests, split on the import paths. The root
+// node has an empty path and stands for all packages.
+func newPackageTree(allTests map[string]*testStatus, allPackages map[string]*packageStatus) *packageNode {
+	root := &packageNode{Name: "All packages"}
+	nodes := map[string]*packageNode{}
+	node := func(pkg string) *packageNode {
+		if n, exists := nodes[pkg]; exists {
+			return n
+		}
+		n := root
+		for _, name := range strings.Split(pkg, "/") {
+			n = n.child(name)
+		}
+		n.IsPackage = true
+		nodes[pkg] = n
+		return n
+	}
+	for name, pkg := range allPackages {
+		node(name).ElapsedTime = pkg.ElapsedTime
+	}
+	for _, status := range allTests {
+		n := node(status.Package)
+		switch {
+		case status.isKnownFailure():
+			n.NumOfKnownFailures++
+		case status.Passed:
+			n.NumOfTestPassed++
+		case status.Skipped:
+			n.NumOfTestSkipped++
+		default:
+			n.NumOfTestFailed++
+		}
+	}
+	root.sum()
+	root.compact()
+	return root
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPackageTree(t *testing.T) {
+	assertions := assert.New(t)
+	allTests := map[string]*testStatus{
+		"github.com/o/r/a.TestA":   {TestName: "TestA", Package: "github.com/o/r/a", Passed: true},
+		"github.com/o/r/a/b.TestB": {TestName: "TestB", Package: "github.com/o/r/a/b"},
+		"github.com/o/r/a/b.TestC": {TestName: "TestC", Package: "github.com/o/r/a/b", KnownFailure: &knownFailure{Test: "TestC"}},
+		"github.com/o/r/c.TestD":   {TestName: "TestD", Package: "github.com/o/r/c", Skipped: true},
+	}
+	allPackages := map[string]*packageStatus{
+		"github.com/o/r/a":   {Name: "github.com/o/r/a", ElapsedTime: 1},
+		"github.com/o/r/a/b": {Name: "github.com/o/r/a/b", ElapsedTime: 2},
+		"github.com/o/r/c":   {Name: "github.com/o/r/c", ElapsedTime: 0.5},
+	}
+	root := newPackageTree(allTests, allPackages)
+
+	assertions.Equal("", root.Path)
+	assertions.Equal(4, root.NumOfTests())
+	assertions.Equal(3.5, root.ElapsedTime)
+	assertions.Len(root.Children, 1)
+
+	module := root.Children[0]
+	assertions.Equal("github.com/o/r", module.Name)
+	assertions.Equal("github.com/o/r", module.Path)
+	assertions.False(module.IsPackage)
+	assertions.Len(module.Children, 2)
+
+	a, c := module.Children[0], module.Children[1]
+	assertions.Equal("a", a.Name)
+	assertions.True(a.IsPackage)
+	assertions.Equal(1, a.NumOfTestPassed)
+	assertions.Equal(1, a.NumOfTestFailed)
+	assertions.Equal(1, a.NumOfKnownFailures)
+	assertions.Equal(3.0, a.ElapsedTime)
+	assertions.Equal("github.com/o/r/a/b", a.Children[0].Path)
+	assertions.Equal("c", c.Name)
+	assertions.Equal(1, c.NumOfTestSkipped)
+	assertions.Empty(c.Children)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultRegressionFactor  = 2
+	defaultRegressionMinimum = 100 * time.Millisecond
+)
+
+type (
+	// durationBaseline holds the expected duration in seconds of the tests, by package and test
+	// name, and of the packages.
+	durationBaseline struct {
+		tests    map[string]float64
+		packages map[string]float64
+	}
+
+	// durationRegression is a test or package that took longer than its baseline. The name of a
+	// package regression is empty.
+	durationRegression struct {
+		Package  string  `json:"package"`
+		Name     string  `json:"name,omitempty"`
+		Baseline float64 `json:"baseline"`
+		Elapsed  float64 `json:"elapsed"`
+	}
+)
+
+// Change returns the relative change of the duration, e.g. "+150%".
+func (r *durationRegression) Change() string {
+	return durationChange(r.Baseline, r.Elapsed)
+}
+
+func durationChange(baseline, elapsed float64) string {
+	if baseline == 0 {
+		return "new"
+	}
+	return fmt.Sprintf("%+.0f%%", (elapsed-baseline)*100/baseline)
+}
+
+// isDurationRegression reports whether the duration grew by at least the factor and by at least
+// the minimum.
+func isDurationRegression(baseline, elapsed float64, factor float64, minimum time.Duration) bool {
+	increase := time.Duration((elapsed - baseline) * float64(time.Second))
+	return increase > 0 && increase >= minimum && elapsed >= baseline*factor
+}
+
+// readBaselineFile uses the passed tests and the packages of a previous go test -json output as
+// the baseline.
+func readBaselineFile(filename string, flags *cmdFlags, cmd *cobra.Command) (*durationBaseline, error) {
+	allPackages, allTests, err := readTestDataFromFile(filename, flags, cmd, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	baseline := &durationBaseline{tests: map[string]float64{}, packages: map[string]float64{}}
+	for key, status := range allTests {
+		if status.statusText() == "pass" {
+			baseline.tests[key] = status.ElapsedTime
+		}
+	}
+	for name, pkg := range allPackages {
+		if pkg.Passed {
+			baseline.packages[name] = pkg.ElapsedTime
+		}
+	}
+	return baseline, nil
+}
+
+// historyBaseline uses the median duration of the passed runs of every test and package in the
+// records as the baseline.
+func historyBaseline(records []*historyRecord) *durationBaseline {
+	tests := map[string][]float64{}
+	packages := map[string][]float64{}
+	for _, record := range records {
+		for _, test := range record.Tests {
+			if test.Status == "pass" {
+				key := test.Package + "." + test.Name
+				tests[key] = append(tests[key], test.Elapsed)
+			}
+		}
+		for _, pkg := range record.Packages {
+			packages[pkg.Name] = append(packages[pkg.Name], pkg.Elapsed)
+		}
+	}
+	baseline := &durationBaseline{tests: map[string]float64{}, packages: map[string]float64{}}
+	for key, durations := range tests {
+		baseline.tests[key] = median(durations)
+	}
+	for name, durations := range packages {
+		baseline.packages[name] = median(durations)
+	}
+	return baseline
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	if len(sorted)%2 == 1 {
+		return sorted[len(sorted)/2]
+	}
+	return (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+}
+
+// compare flags the passed tests and the passed packages that got slower than the baseline, and
+// adds them to the report, slowest increase first.
+func (b *durationBaseline) compare(tmplData *templateData, allTests map[string]*testStatus, allPackages map[string]*packageStatus, factor float64, minimum time.Duration) {
+	tmplData.SlowerTests = nil
+	tmplData.SlowerPackages = nil
+	for _, status := range sortedTests(allTests) {
+		baseline, exists := b.tests[status.Package+"."+status.TestName]
+		if !exists || status.statusText() != "pass" {
+			continue
+		}
+		status.BaselineElapsed = baseline
+		if isDurationRegression(baseline, status.ElapsedTime, factor, minimum) {
+			status.Slower = true
+			tmplData.SlowerTests = append(tmplData.SlowerTests, &durationRegression{
+				Package: status.Package, Name: status.TestName, Baseline: baseline, Elapsed: status.ElapsedTime,
+			})
+		}
+	}
+	for name, pkg := range allPackages {
+		baseline, exists := b.packages[name]
+		if !exists || !pkg.Passed {
+			continue
+		}
+		pkg.BaselineElapsed = baseline
+		if isDurationRegression(baseline, pkg.ElapsedTime, factor, minimum) {
+			pkg.Slower = true
+			tmplData.SlowerPackages = append(tmplData.SlowerPackages, &durationRegression{
+				Package: name, Baseline: baseline, Elapsed: pkg.ElapsedTime,
+			})
+		}
+	}
+	for _, regressions := range [][]*durationRegression{tmplData.SlowerTests, tmplData.SlowerPackages} {
+		sort.SliceStable(regressions, func(i, j int) bool {
+			increaseI, increaseJ := regressions[i].Elapsed-regressions[i].Baseline, regressions[j].Elapsed-regressions[j].Baseline
+			if increaseI != increaseJ {
+				return increaseI > increaseJ
+			}
+			return regressions[i].Package < regressions[j].Package
+		})
+	}
+}
+
+// loadBaseline reads the baseline selected with --baseline or --baseline-history, or returns nil
+// if there is none.
+func loadBaseline(flags *cmdFlags, cmd *cobra.Command) (*durationBaseline, error) {
+	switch {
+	case flags.baselineFlag != "":
+		baseline, err := readBaselineFile(flags.baselineFlag, flags, cmd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read baseline file %s: %w", flags.baselineFlag, err)
+		}
+		return baseline, nil
+	case flags.baselineHistory:
+		records, err := readHistory(filepath.Join(flags.historyDir, historyFileName), flags.historyRuns)
+		if err != nil {
+			return nil, err
+		}
+		return historyBaseline(records), nil
+	}
+	return nil, nil
+}
+
+func validateRegressionFlags(flags *cmdFlags) error {
+	if flags.baselineFlag != "" && flags.baselineHistory {
+		return errors.New("--baseline and --baseline-history cannot be used together")
+	}
+	if flags.baselineHistory && flags.historyDir == "" {
+		return errors.New("--baseline-history requires --history-dir")
+	}
+	if flags.regressionFactor < 1 {
+		return fmt.Errorf("--regression-factor must be at least 1, got %g", flags.regressionFactor)
+	}
+	return nil
+}
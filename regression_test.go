@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDurationRegression(t *testing.T) {
+	assertions := assert.New(t)
+	assertions.True(isDurationRegression(1, 2, 2, 100*time.Millisecond))
+	assertions.False(isDurationRegression(1, 1.9, 2, 100*time.Millisecond))
+	assertions.False(isDurationRegression(0.01, 0.05, 2, 100*time.Millisecond))
+	assertions.True(isDurationRegression(0.01, 0.05, 2, 0))
+	assertions.False(isDurationRegression(0, 0, 1, 0))
+	assertions.Equal("+100%", durationChange(1, 2))
+	assertions.Equal("new", durationChange(0, 2))
+}
+
+func TestHistoryBaseline(t *testing.T) {
+	assertions := assert.New(t)
+	records := []*historyRecord{
+		{Tests: []historyTest{{Package: "pkg", Name: "TestA", Status: "pass", Elapsed: 1}}, Packages: []historyPackage{{Name: "pkg", Elapsed: 2}}},
+		{Tests: []historyTest{{Package: "pkg", Name: "TestA", Status: "fail", Elapsed: 9}}},
+		{Tests: []historyTest{{Package: "pkg", Name: "TestA", Status: "pass", Elapsed: 3}}, Packages: []historyPackage{{Name: "pkg", Elapsed: 4}}},
+		{Tests: []historyTest{{Package: "pkg", Name: "TestA", Status: "pass", Elapsed: 2}}},
+	}
+	baseline := historyBaseline(records)
+	assertions.Equal(map[string]float64{"pkg.TestA": 2}, baseline.tests)
+	assertions.Equal(map[string]float64{"pkg": 3}, baseline.packages)
+}
+
+func TestDurationBaselineCompare(t *testing.T) {
+	assertions := assert.New(t)
+	baseline := &durationBaseline{
+		tests:    map[string]float64{"pkg.TestA": 0.5, "pkg.TestB": 0.5, "pkg.TestC": 0.1},
+		packages: map[string]float64{"pkg": 1, "other": 1},
+	}
+	allTests := map[string]*testStatus{
+		"pkg.TestA": {TestName: "TestA", Package: "pkg", Passed: true, ElapsedTime: 1.5},
+		"pkg.TestB": {TestName: "TestB", Package: "pkg", Passed: true, ElapsedTime: 0.6},
+		"pkg.TestC": {TestName: "TestC", Package: "pkg", ElapsedTime: 5},
+		"pkg.TestD": {TestName: "TestD", Package: "pkg", Passed: true, ElapsedTime: 5},
+	}
+	allPackages := map[string]*packageStatus{
+		"pkg":   {Name: "pkg", Passed: true, ElapsedTime: 3},
+		"other": {Name: "other", Passed: true, ElapsedTime: 1},
+	}
+	tmplData := &templateData{}
+	baseline.compare(tmplData, allTests, allPackages, 2, 100*time.Millisecond)
+
+	assertions.Equal([]*durationRegression{{Package: "pkg", Name: "TestA", Baseline: 0.5, Elapsed: 1.5}}, tmplData.SlowerTests)
+	assertions.Equal([]*durationRegression{{Package: "pkg", Baseline: 1, Elapsed: 3}}, tmplData.SlowerPackages)
+	assertions.True(allTests["pkg.TestA"].Slower)
+	assertions.False(allTests["pkg.TestB"].Slower)
+	assertions.Equal(0.5, allTests["pkg.TestB"].BaselineElapsed)
+	// failed tests and tests without a baseline are not compared
+	assertions.False(allTests["pkg.TestC"].Slower)
+	assertions.False(allTests["pkg.TestD"].Slower)
+	assertions.True(allPackages["pkg"].Slower)
+
+	results := (&gates{failOn: []string{"slower"}, maxSkipped: -1}).evaluate(tmplData, allTests, allPackages)
+	assertions.Equal([]gateResult{{Rule: "fail-on slower", Passed: false, Message: "2 slower: pkg.TestA, package pkg"}}, results)
+
+	report := &bytes.Buffer{}
+	assertions.Nil(renderHTMLReport(report, tmplData))
+	assertions.Contains(report.String(), "Slower: <strong>2</strong>")
+}
+
+func TestReadBaselineFileMissing(t *testing.T) {
+	assertions := assert.New(t)
+	_, err := readBaselineFile(filepath.Join("testdata", "missing.json"), &cmdFlags{}, nil)
+	assertions.ErrorIs(err, os.ErrNotExist)
+}
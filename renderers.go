@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type (
+	// report is the parsed and enriched test run that every output format is rendered from.
+	report struct {
+		tmplData    *templateData
+		allTests    map[string]*testStatus
+		allPackages map[string]*packageStatus
+		summary     *jsonSummary
+		flags       *cmdFlags
+	}
+
+	// renderer writes the report in one output format.
+	renderer func(w io.Writer, r *report) error
+
+	// outputFormat is a requested output: the name of a renderer and the file it is written to.
+	outputFormat struct {
+		name     string
+		filename string
+	}
+)
+
+// renderers holds the output formats by the name used with the --format flag.
+var renderers = map[string]renderer{
+	"html": func(w io.Writer, r *report) error {
+		return renderHTMLReport(w, r.tmplData)
+	},
+	"json": func(w io.Writer, r *report) error {
+		return writeJSONSummary(w, r.summary)
+	},
+	"markdown": func(w io.Writer, r *report) error {
+		_, err := io.WriteString(w, renderMarkdownSummary(r.summary, r.flags.markdownMaxSize))
+		return err
+	},
+	"tap": func(w io.Writer, r *report) error {
+		return writeTAP(w, r.summary)
+	},
+	"ctrf": func(w io.Writer, r *report) error {
+		return writeCTRF(w, r.summary)
+	},
+	"junit": func(w io.Writer, r *report) error {
+		return writeJUnit(w, r.summary)
+	},
+	annotationsGitLab: func(w io.Writer, r *report) error {
+		return writeGitLabCodeQuality(w, collectAnnotations(r.summary))
+	},
+}
+
+func rendererNames() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *report) render(output outputFormat) error {
+	if output.name == splitFormat {
+		return writeSplitReport(output.filename, r.tmplData)
+	}
+	return writeOutputFile(output.filename, func(w io.Writer) error {
+		return renderers[output.name](w, r)
+	})
+}
+
+// parseOutputFormats returns the outputs requested with --format and with the flags of the
+// individual formats. The HTML report is written to --output unless --format or --split is used
+// without html and --output is not set explicitly.
+func parseOutputFormats(flags *cmdFlags, outputFlagChanged bool) ([]outputFormat, error) {
+	var outputs []outputFormat
+	for _, format := range flags.formats {
+		kv := strings.SplitN(format, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("malformed format %q; expected name=path", format)
+		}
+		if _, exists := renderers[kv[0]]; !exists {
+			return nil, fmt.Errorf("unknown format %q; expected one of %s", kv[0], strings.Join(rendererNames(), ", "))
+		}
+		outputs = append(outputs, outputFormat{name: kv[0], filename: kv[1]})
+	}
+	if outputFlagChanged || (len(flags.formats) == 0 && flags.splitFlag == "") {
+		outputs = append([]outputFormat{{name: "html", filename: flags.outputFlag}}, outputs...)
+	}
+	for _, output := range []outputFormat{
+		{name: splitFormat, filename: flags.splitFlag},
+		{name: "json", filename: flags.jsonFlag},
+		{name: "markdown", filename: flags.markdownFlag},
+		{name: "tap", filename: flags.tapFlag},
+		{name: "ctrf", filename: flags.ctrfFlag},
+	} {
+		if output.filename != "" {
+			outputs = append(outputs, output)
+		}
+	}
+	if flags.annotationsFlag == annotationsGitLab {
+		outputs = append(outputs, outputFormat{name: annotationsGitLab, filename: flags.annotationsOutputFlag})
+	}
+	return outputs, nil
+}
+
+// htmlReportPath returns the path of the first HTML report of the outputs, or an empty string if
+// none is written.
+func htmlReportPath(outputs []outputFormat) string {
+	for _, output := range outputs {
+		if output.name == "html" {
+			return output.filename
+		}
+		if output.name == splitFormat {
+			return filepath.Join(output.filename, splitIndexFile)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOutputFormats(t *testing.T) {
+	assertions := assert.New(t)
+	flags := &cmdFlags{outputFlag: "report.html", jsonFlag: "summary.json"}
+	outputs, err := parseOutputFormats(flags, false)
+	assertions.Nil(err)
+	assertions.Equal([]outputFormat{{name: "html", filename: "report.html"}, {name: "json", filename: "summary.json"}}, outputs)
+
+	flags = &cmdFlags{outputFlag: "report.html", formats: []string{"junit=junit.xml", "tap=results.tap"}}
+	outputs, err = parseOutputFormats(flags, false)
+	assertions.Nil(err)
+	assertions.Equal([]outputFormat{{name: "junit", filename: "junit.xml"}, {name: "tap", filename: "results.tap"}}, outputs)
+
+	outputs, err = parseOutputFormats(flags, true)
+	assertions.Nil(err)
+	assertions.Equal(outputFormat{name: "html", filename: "report.html"}, outputs[0])
+
+	flags = &cmdFlags{formats: []string{"gitlab=gl.json"}, annotationsFlag: annotationsGitLab, annotationsOutputFlag: "cq.json"}
+	outputs, err = parseOutputFormats(flags, false)
+	assertions.Nil(err)
+	assertions.Equal([]outputFormat{{name: "gitlab", filename: "gl.json"}, {name: "gitlab", filename: "cq.json"}}, outputs)
+
+	flags = &cmdFlags{outputFlag: "report.html", splitFlag: "site"}
+	outputs, err = parseOutputFormats(flags, false)
+	assertions.Nil(err)
+	assertions.Equal([]outputFormat{{name: splitFormat, filename: "site"}}, outputs)
+}
+
+func TestParseOutputFormatsIfMalformed(t *testing.T) {
+	assertions := assert.New(t)
+	_, err := parseOutputFormats(&cmdFlags{formats: []string{"json"}}, false)
+	assertions.EqualError(err, `malformed format "json"; expected name=path`)
+	_, err = parseOutputFormats(&cmdFlags{formats: []string{"pdf=report.pdf"}}, false)
+	assertions.EqualError(err, `unknown format "pdf"; expected one of ctrf, gitlab, html, json, junit, markdown, tap`)
+}
+
+func TestReportRenderAllFormats(t *testing.T) {
+	assertions := assert.New(t)
+	tmplData := &templateData{ReportTitle: "test-title", numOfTestsPerGroup: 20}
+	allTests := map[string]*testStatus{
+		"pkg/a.TestA": {TestName: "TestA", Package: "pkg/a", Passed: true, Output: []string{}},
+	}
+	allPackages := map[string]*packageStatus{"pkg/a": {Name: "pkg/a", Passed: true}}
+	prepareReport(tmplData, allTests, testFileDetailsByPackage{}, 0)
+	r := &report{
+		tmplData:    tmplData,
+		allTests:    allTests,
+		allPackages: allPackages,
+		summary:     newJSONSummary(tmplData, allTests, allPackages),
+		flags:       &cmdFlags{},
+	}
+	dir := t.TempDir()
+	for _, name := range rendererNames() {
+		filename := filepath.Join(dir, name)
+		assertions.Nil(r.render(outputFormat{name: name, filename: filename}), name)
+		content, err := os.ReadFile(filename)
+		assertions.Nil(err)
+		assertions.NotEmpty(bytes.TrimSpace(content), name)
+	}
+}
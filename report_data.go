@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// outputBlockSize is the number of tests whose outputs are compressed together. The report only
+// decompresses the block of a test when its output is shown, so that a large report opens fast.
+const outputBlockSize = 256
+
+type (
+	// reportTest is a test as embedded in the HTML report: its output is left out and stored in
+	// the output block and at the position given by OutputBlock and OutputIndex.
+	reportTest struct {
+		*testStatus
+		ID          string
+		Output      []string `json:"Output,omitempty"`
+		OutputBlock int
+		OutputIndex int
+	}
+
+	reportGroup struct {
+		*testGroupData
+		TestResults []*reportTest
+	}
+)
+
+// testID returns the id of a test in the report and in its links. It is derived from the package
+// and the full name of the test, so it is the same in every run and unique across packages.
+func testID(pkg, name string) string {
+	sum := sha256.Sum256([]byte(pkg + "\x00" + name))
+	return "test-" + hex.EncodeToString(sum[:8])
+}
+
+// testOutputHTML returns the output of a test as HTML. Every failure location, e.g.
+// "foo_test.go:12: message", is preceded by a link to it, numbered like the failures of the JSON
+// summary.
+func testOutputHTML(status *testStatus, id string) string {
+	w := &ansiHTMLWriter{}
+	failure := 0
+	for _, chunk := range status.Output {
+		for _, line := range strings.SplitAfter(chunk, "\n") {
+			if failureLocationRegExp.MatchString(strings.TrimRight(line, "\n")) {
+				fmt.Fprintf(w, `<a class="failureLink" id="%s-failure-%d" href="?testcase=%s&amp;failure=%d" title="Link to this failure">#</a>`, id, failure, id, failure)
+				failure++
+			}
+			w.writeText(line)
+		}
+	}
+	return w.String()
+}
+
+// newReportGroups returns the test groups as embedded in the report and the outputs of their
+// tests, as HTML, by output block. The blocks hold the tests of one package each if byPackage is set, or
+// outputBlockSize tests each.
+func newReportGroups(tmplData *templateData, byPackage bool) ([]*reportGroup, [][]string) {
+	var groups []*reportGroup
+	var blocks [][]string
+	packageBlocks := map[string]int{}
+	numOfTests := 0
+	for _, group := range tmplData.TestResults {
+		reportGroup := &reportGroup{testGroupData: group}
+		for _, status := range group.TestResults {
+			block := numOfTests / outputBlockSize
+			if byPackage {
+				var exists bool
+				if block, exists = packageBlocks[status.Package]; !exists {
+					block = len(blocks)
+					packageBlocks[status.Package] = block
+				}
+			}
+			if block == len(blocks) {
+				blocks = append(blocks, nil)
+			}
+			id := testID(status.Package, status.TestName)
+			reportGroup.TestResults = append(reportGroup.TestResults, &reportTest{testStatus: status, ID: id, OutputBlock: block, OutputIndex: len(blocks[block])})
+			blocks[block] = append(blocks[block], testOutputHTML(status, id))
+			numOfTests++
+		}
+		groups = append(groups, reportGroup)
+	}
+	return groups, blocks
+}
+
+// gzipBase64 compresses the data with gzip and encodes it as base64.
+func gzipBase64(data []byte) (string, error) {
+	buffer := &bytes.Buffer{}
+	writer := gzip.NewWriter(buffer)
+	if _, err := writer.Write(data); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buffer.Bytes()), nil
+}
+
+// encodeReportData sets the compressed test groups and output blocks that the report script
+// decompresses in the browser. Both are JS literals: a string and an array of strings.
+func encodeReportData(tmplData *templateData) error {
+	groups, outputs := newReportGroups(tmplData, false)
+	content, err := json.Marshal(groups)
+	if err != nil {
+		return err
+	}
+	data, err := gzipBase64(content)
+	if err != nil {
+		return err
+	}
+	blocks := []string{}
+	for _, block := range outputs {
+		content, err := json.Marshal(block)
+		if err != nil {
+			return err
+		}
+		compressed, err := gzipBase64(content)
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, compressed)
+	}
+	// base64 strings are safe JS string literals
+	dataLiteral, _ := json.Marshal(data)
+	blocksLiteral, _ := json.Marshal(blocks)
+	tmplData.ReportData = template.JS(dataLiteral)
+	tmplData.OutputBlocks = template.JS(blocksLiteral)
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func gunzipBase64(t *testing.T, literal string, v interface{}) {
+	var data string
+	assert.Nil(t, json.Unmarshal([]byte(literal), &data))
+	content, err := base64.StdEncoding.DecodeString(data)
+	assert.Nil(t, err)
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	assert.Nil(t, err)
+	content, err = io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(content, v))
+}
+
+func TestEncodeReportData(t *testing.T) {
+	assertions := assert.New(t)
+	var tests []*testStatus
+	for i := 0; i < outputBlockSize+10; i++ {
+		tests = append(tests, &testStatus{
+			TestName: fmt.Sprintf("Test%03d", i),
+			Package:  "pkg/a",
+			Passed:   true,
+			Output:   []string{fmt.Sprintf("=== RUN   Test%03d\n", i), "--- PASS\n"},
+		})
+	}
+	tmplData := &templateData{TestResults: groupTests(tests, groupByChunk, 100)}
+	assertions.Nil(encodeReportData(tmplData))
+
+	var groups []struct {
+		Name        string
+		TestResults []map[string]interface{}
+	}
+	gunzipBase64(t, string(tmplData.ReportData), &groups)
+	assertions.Len(groups, 3)
+	assertions.Equal("Tests 101-200", groups[1].Name)
+	test := groups[2].TestResults[65]
+	assertions.Equal("Test265", test["TestName"])
+	assertions.Equal(1.0, test["OutputBlock"])
+	assertions.Equal(9.0, test["OutputIndex"])
+	assertions.NotContains(test, "Output")
+
+	var blocks []string
+	assertions.Nil(json.Unmarshal([]byte(tmplData.OutputBlocks), &blocks))
+	assertions.Len(blocks, 2)
+	var outputs []string
+	blockLiteral, _ := json.Marshal(blocks[1])
+	gunzipBase64(t, string(blockLiteral), &outputs)
+	assertions.Len(outputs, 10)
+	assertions.Equal("=== RUN   Test265\n--- PASS\n", outputs[265-outputBlockSize])
+}
+
+func TestTestID(t *testing.T) {
+	assertions := assert.New(t)
+	id := testID("pkg/a", "TestX/sub case")
+	assertions.Regexp(`^test-[0-9a-f]{16}$`, id)
+	assertions.Equal(id, testID("pkg/a", "TestX/sub case"))
+	assertions.NotEqual(id, testID("pkg/b", "TestX/sub case"))
+	assertions.NotEqual(testID("pkg/a", "TestX"), testID("pkg/a.TestX", ""))
+}
+
+func TestTestOutputHTML(t *testing.T) {
+	assertions := assert.New(t)
+	status := &testStatus{Output: []string{
+		"=== RUN   TestX\n",
+		"    x_test.go:10: \x1b[31mgot <nil>\n",
+		"    x_test.go:12: second\x1b[0m\n",
+	}}
+	assertions.Equal("=== RUN   TestX\n"+
+		`<a class="failureLink" id="test-1-failure-0" href="?testcase=test-1&amp;failure=0" title="Link to this failure">#</a>`+
+		`    x_test.go:10: <span class="ansiFg1">got &lt;nil&gt;`+"\n</span>"+
+		`<a class="failureLink" id="test-1-failure-1" href="?testcase=test-1&amp;failure=1" title="Link to this failure">#</a>`+
+		`<span class="ansiFg1">    x_test.go:12: second</span>`+"\n",
+		testOutputHTML(status, "test-1"))
+}
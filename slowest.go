@@ -0,0 +1,140 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	defaultSlowestTests = 10
+
+	// slowestTestsPerPackage is the number of slowest tests whose share of the package duration is
+	// shown.
+	slowestTestsPerPackage = 3
+)
+
+type (
+	// slowestPanel shows where the time of the test run went.
+	slowestPanel struct {
+		Tests     []*testStatus
+		Packages  []*slowPackage
+		Histogram []*histogramBucket
+	}
+
+	slowPackage struct {
+		Name         string
+		ElapsedTime  float64
+		NumOfTests   int
+		SlowestTests []*testStatus
+		// SlowestShare is the percentage of the package duration spent in its slowest top level tests.
+		SlowestShare float64
+	}
+
+	histogramBucket struct {
+		Label string
+		Max   float64
+		Count int
+		// Percent is the bar width relative to the largest bucket.
+		Percent float64
+	}
+)
+
+// histogramBuckets are the upper bounds, in seconds, of the test duration histogram.
+var histogramBuckets = []histogramBucket{
+	{Label: "< 10ms", Max: 0.01},
+	{Label: "10ms - 100ms", Max: 0.1},
+	{Label: "100ms - 1s", Max: 1},
+	{Label: "1s - 10s", Max: 10},
+	{Label: "10s - 1m", Max: 60},
+	{Label: "≥ 1m"},
+}
+
+// newSlowestPanel returns the n slowest tests and packages and the histogram of the test
+// durations. Tests that took no measurable time are not listed as slowest. The histogram counts
+// tests without subtests only, as the duration of a parent test includes the durations of its
+// subtests.
+func newSlowestPanel(allTests map[string]*testStatus, allPackages map[string]*packageStatus, n int) *slowestPanel {
+	panel := &slowestPanel{}
+	tests := sortedTests(allTests)
+	hasSubtests := map[string]bool{}
+	for _, status := range tests {
+		if i := strings.LastIndex(status.TestName, "/"); i >= 0 {
+			hasSubtests[status.Package+"."+status.TestName[:i]] = true
+		}
+	}
+	for i := range histogramBuckets {
+		bucket := histogramBuckets[i]
+		panel.Histogram = append(panel.Histogram, &bucket)
+	}
+	topLevelTests := map[string][]*testStatus{}
+	for _, status := range tests {
+		if !hasSubtests[status.Package+"."+status.TestName] {
+			for _, bucket := range panel.Histogram {
+				if bucket.Max == 0 || status.ElapsedTime < bucket.Max {
+					bucket.Count++
+					break
+				}
+			}
+		}
+		if !strings.Contains(status.TestName, "/") {
+			topLevelTests[status.Package] = append(topLevelTests[status.Package], status)
+		}
+	}
+	maxCount := 0
+	for _, bucket := range panel.Histogram {
+		if bucket.Count > maxCount {
+			maxCount = bucket.Count
+		}
+	}
+	for _, bucket := range panel.Histogram {
+		if maxCount > 0 {
+			bucket.Percent = float64(bucket.Count) * 100 / float64(maxCount)
+		}
+	}
+
+	for _, status := range tests {
+		if status.ElapsedTime > 0 {
+			panel.Tests = append(panel.Tests, status)
+		}
+	}
+	sort.SliceStable(panel.Tests, func(i, j int) bool {
+		return panel.Tests[i].ElapsedTime > panel.Tests[j].ElapsedTime
+	})
+	if len(panel.Tests) > n {
+		panel.Tests = panel.Tests[:n]
+	}
+
+	for name, pkg := range allPackages {
+		sp := &slowPackage{Name: name, ElapsedTime: pkg.ElapsedTime, NumOfTests: len(topLevelTests[name])}
+		slowest := append([]*testStatus{}, topLevelTests[name]...)
+		sort.SliceStable(slowest, func(i, j int) bool {
+			return slowest[i].ElapsedTime > slowest[j].ElapsedTime
+		})
+		if len(slowest) > slowestTestsPerPackage {
+			slowest = slowest[:slowestTestsPerPackage]
+		}
+		sp.SlowestTests = slowest
+		if pkg.ElapsedTime > 0 {
+			var elapsed float64
+			for _, status := range slowest {
+				elapsed += status.ElapsedTime
+			}
+			sp.SlowestShare = elapsed * 100 / pkg.ElapsedTime
+			if sp.SlowestShare > 100 {
+				// parallel tests can take longer in total than their package
+				sp.SlowestShare = 100
+			}
+		}
+		panel.Packages = append(panel.Packages, sp)
+	}
+	sort.Slice(panel.Packages, func(i, j int) bool {
+		if panel.Packages[i].ElapsedTime != panel.Packages[j].ElapsedTime {
+			return panel.Packages[i].ElapsedTime > panel.Packages[j].ElapsedTime
+		}
+		return panel.Packages[i].Name < panel.Packages[j].Name
+	})
+	if len(panel.Packages) > n {
+		panel.Packages = panel.Packages[:n]
+	}
+	return panel
+}
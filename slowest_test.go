@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSlowestPanel(t *testing.T) {
+	assertions := assert.New(t)
+	allTests := map[string]*testStatus{
+		"a.TestA":     {TestName: "TestA", Package: "a", ElapsedTime: 2},
+		"a.TestA/one": {TestName: "TestA/one", Package: "a", ElapsedTime: 1.5},
+		"a.TestA/two": {TestName: "TestA/two", Package: "a", ElapsedTime: 0.5},
+		"a.TestB":     {TestName: "TestB", Package: "a", ElapsedTime: 0.005},
+		"a.TestC":     {TestName: "TestC", Package: "a", ElapsedTime: 0},
+		"a.TestD":     {TestName: "TestD", Package: "a", ElapsedTime: 0.05},
+		"a.TestE":     {TestName: "TestE", Package: "a", ElapsedTime: 0.02},
+		"b.TestF":     {TestName: "TestF", Package: "b", ElapsedTime: 90},
+	}
+	allPackages := map[string]*packageStatus{
+		"a": {Name: "a", ElapsedTime: 4},
+		"b": {Name: "b", ElapsedTime: 91},
+	}
+	panel := newSlowestPanel(allTests, allPackages, 2)
+
+	assertions.Equal([]*testStatus{allTests["b.TestF"], allTests["a.TestA"]}, panel.Tests)
+
+	assertions.Len(panel.Packages, 2)
+	assertions.Equal("b", panel.Packages[0].Name)
+	assertions.Equal("a", panel.Packages[1].Name)
+	assertions.Equal(5, panel.Packages[1].NumOfTests)
+	assertions.Equal([]*testStatus{allTests["a.TestA"], allTests["a.TestD"], allTests["a.TestE"]}, panel.Packages[1].SlowestTests)
+	assertions.InDelta(51.75, panel.Packages[1].SlowestShare, 0.001)
+
+	counts := map[string]int{}
+	for _, bucket := range panel.Histogram {
+		counts[bucket.Label] = bucket.Count
+	}
+	// TestA is left out of the histogram as its duration includes its subtests
+	assertions.Equal(map[string]int{"< 10ms": 2, "10ms - 100ms": 2, "100ms - 1s": 1, "1s - 10s": 1, "10s - 1m": 0, "≥ 1m": 1}, counts)
+	assertions.Equal(100.0, panel.Histogram[0].Percent)
+	assertions.Equal(50.0, panel.Histogram[2].Percent)
+
+	allTests["b.TestF"].ElapsedTime = 90.123456
+	report := &bytes.Buffer{}
+	assertions.Nil(renderHTMLReport(report, &templateData{Slowest: panel}))
+	assertions.Contains(report.String(), `<td class="duration">90.12s</td><td>TestF</td>`)
+	assertions.NotContains(report.String(), "90.123456")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// splitFormat is the output written with --split.
+	splitFormat      = "split"
+	splitIndexFile   = "index.html"
+	splitDataDir     = "data"
+	splitSummaryName = "summary"
+)
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// splitSummary is data/summary.json of a split report: the test groups without the outputs of
+// the tests and the data files holding the outputs, one per package.
+type splitSummary struct {
+	Groups      []*reportGroup `json:"groups"`
+	OutputFiles []string       `json:"outputFiles"`
+}
+
+// packageFileName returns the name of the data file of a package, without the extension. Names
+// are unique regardless of case, since file systems may ignore it, and never clash with the
+// summary.
+func packageFileName(pkg string, used map[string]bool) string {
+	base := strings.Trim(unsafeFileNameChars.ReplaceAllString(pkg, "_"), "._")
+	if base == "" {
+		base = "package"
+	}
+	name := base
+	for i := 2; used[strings.ToLower(name)] || strings.EqualFold(name, splitSummaryName); i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+// writeSplitReport writes the report to dir as index.html, which fetches the test groups from
+// data/summary.json and the outputs of the tests from one data file per package on demand.
+func writeSplitReport(dir string, tmplData *templateData) error {
+	dataDir := filepath.Join(dir, splitDataDir)
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+	groups, blocks := newReportGroups(tmplData, true)
+	blockPackages := make([]string, len(blocks))
+	for _, group := range groups {
+		for _, test := range group.TestResults {
+			blockPackages[test.OutputBlock] = test.Package
+		}
+	}
+	summary := splitSummary{Groups: groups, OutputFiles: []string{}}
+	used := map[string]bool{}
+	for i, outputs := range blocks {
+		name := packageFileName(blockPackages[i], used) + ".json"
+		if err := writeJSONFile(filepath.Join(dataDir, name), outputs); err != nil {
+			return err
+		}
+		// the page fetches the files relative to index.html
+		summary.OutputFiles = append(summary.OutputFiles, splitDataDir+"/"+name)
+	}
+	if err := writeJSONFile(filepath.Join(dataDir, splitSummaryName+".json"), summary); err != nil {
+		return err
+	}
+	tmplData.SplitOutput = true
+	defer func() {
+		tmplData.SplitOutput = false
+	}()
+	return writeOutputFile(filepath.Join(dir, splitIndexFile), func(w io.Writer) error {
+		return renderHTMLReport(w, tmplData)
+	})
+}
+
+func writeJSONFile(filename string, v interface{}) error {
+	return writeOutputFile(filename, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(v)
+	})
+}
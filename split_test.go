@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageFileName(t *testing.T) {
+	assertions := assert.New(t)
+	used := map[string]bool{}
+	assertions.Equal("github.com_org_repo_pkg", packageFileName("github.com/org/repo/pkg", used))
+	assertions.Equal("github.com_org_repo_PKG_2", packageFileName("github.com/org/repo/PKG", used))
+	assertions.Equal("summary_2", packageFileName("summary", used))
+	assertions.Equal("package", packageFileName("..", used))
+}
+
+func TestWriteSplitReport(t *testing.T) {
+	assertions := assert.New(t)
+	tests := []*testStatus{
+		{TestName: "TestA", Package: "pkg/a", Passed: true, Output: []string{"a1\n", "a2\n"}},
+		{TestName: "TestB", Package: "pkg/b", Output: []string{"b\n"}},
+		{TestName: "TestC", Package: "pkg/a", Skipped: true, Output: []string{"c\n"}},
+	}
+	tmplData := &templateData{ReportTitle: "split", TestResults: groupTests(tests, groupByChunk, 20)}
+	dir := filepath.Join(t.TempDir(), "site")
+	assertions.Nil(writeSplitReport(dir, tmplData))
+	assertions.False(tmplData.SplitOutput)
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	assertions.Nil(err)
+	assertions.Contains(string(index), "fetchJSON('data/summary.json')")
+	assertions.Empty(tmplData.ReportData)
+
+	var summary struct {
+		Groups []struct {
+			TestResults []map[string]interface{}
+		} `json:"groups"`
+		OutputFiles []string `json:"outputFiles"`
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "data", "summary.json"))
+	assertions.Nil(err)
+	assertions.Nil(json.Unmarshal(content, &summary))
+	assertions.Equal([]string{"data/pkg_a.json", "data/pkg_b.json"}, summary.OutputFiles)
+	testC := summary.Groups[0].TestResults[2]
+	assertions.Equal(0.0, testC["OutputBlock"])
+	assertions.Equal(1.0, testC["OutputIndex"])
+	assertions.NotContains(testC, "Output")
+
+	var outputs []string
+	content, err = os.ReadFile(filepath.Join(dir, "data", "pkg_a.json"))
+	assertions.Nil(err)
+	assertions.Nil(json.Unmarshal(content, &outputs))
+	assertions.Equal([]string{"a1\na2\n", "c\n"}, outputs)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	envFormatShell        = "shell"
+	envFormatDotenv       = "dotenv"
+	envFormatGitHubOutput = "github-output"
+	envFormatJSON         = "json"
+)
+
+var envFormats = []string{envFormatShell, envFormatDotenv, envFormatGitHubOutput, envFormatJSON}
+
+func isEnvFormat(format string) bool {
+	for _, f := range envFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// newStatus summarizes the test run for the status env file.
+func newStatus(summary *jsonSummary, duration time.Duration, reportPath string) Status {
+	status := Status{
+		Pass:            summary.Totals.Passed,
+		Fail:            summary.Totals.Failed,
+		Total:           summary.Totals.Total,
+		Skip:            summary.Totals.Skipped,
+		Known:           summary.Totals.Known,
+		ElapsedTime:     duration.String(),
+		PackageFailures: map[string]int{},
+		Report:          reportPath,
+	}
+	for _, pkg := range summary.Packages {
+		// a package also fails when it does not build or fails outside of its tests; the failures
+		// of known failing tests are not counted
+		if pkg.Counts.Failed > 0 || (pkg.Failed && pkg.Counts.Known == 0) {
+			status.FailedPackages++
+			status.PackageFailures[pkg.Name] = pkg.Counts.Failed
+		}
+	}
+	for _, test := range summary.Tests {
+		if test.Flaky {
+			status.Flaky++
+		}
+		if status.FirstFailure == "" && test.Status == "fail" && !test.isKnownFailure() && !summary.hasFailedSubtest(test) {
+			status.FirstFailure = test.Package + "." + test.Name
+		}
+	}
+	return status
+}
+
+// envVariables returns the status as ordered key/value pairs.
+func (s *Status) envVariables() [][2]string {
+	packages := make([]string, 0, len(s.PackageFailures))
+	for name := range s.PackageFailures {
+		packages = append(packages, name)
+	}
+	sort.Strings(packages)
+	packageFailures := make([]string, 0, len(packages))
+	for _, name := range packages {
+		packageFailures = append(packageFailures, fmt.Sprintf("%s:%d", name, s.PackageFailures[name]))
+	}
+	return [][2]string{
+		{"TOTAL", fmt.Sprint(s.Total)},
+		{"PASS", fmt.Sprint(s.Pass)},
+		{"FAIL", fmt.Sprint(s.Fail)},
+		{"SKIP", fmt.Sprint(s.Skip)},
+		{"KNOWN", fmt.Sprint(s.Known)},
+		{"FLAKY", fmt.Sprint(s.Flaky)},
+		{"DURATION", s.ElapsedTime},
+		{"FAILED_PACKAGES", fmt.Sprint(s.FailedPackages)},
+		{"PACKAGE_FAILURES", strings.Join(packageFailures, " ")},
+		{"REPORT", s.Report},
+		{"FIRST_FAILURE", s.FirstFailure},
+	}
+}
+
+var envSafeValueRegExp = regexp.MustCompile(`^[\w./:@%+,=-]*$`)
+
+func shellQuote(value string) string {
+	if envSafeValueRegExp.MatchString(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func dotenvQuote(value string) string {
+	if envSafeValueRegExp.MatchString(value) {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+// formatStatus renders the status in the given env file format.
+func formatStatus(status *Status, format string) ([]byte, error) {
+	if format == envFormatJSON {
+		content, err := json.MarshalIndent(status, "", "  ")
+		return append(content, '\n'), err
+	}
+	b := &strings.Builder{}
+	for _, kv := range status.envVariables() {
+		switch format {
+		case envFormatShell:
+			fmt.Fprintf(b, "export %s=%s\n", kv[0], shellQuote(kv[1]))
+		case envFormatDotenv:
+			fmt.Fprintf(b, "%s=%s\n", kv[0], dotenvQuote(kv[1]))
+		case envFormatGitHubOutput:
+			fmt.Fprintf(b, "%s=%s\n", strings.ToLower(kv[0]), strings.ReplaceAll(kv[1], "\n", " "))
+		default:
+			return nil, fmt.Errorf("unknown env format %q; expected one of %s", format, strings.Join(envFormats, ", "))
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// writeStatus writes the status env file. The github-output format is appended to the file, as
+// $GITHUB_OUTPUT is shared by all steps of a job; the other formats replace it.
+func writeStatus(status *Status, filename string, format string) error {
+	content, err := formatStatus(status, format)
+	if err != nil {
+		return err
+	}
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if format == envFormatGitHubOutput {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(filename, flag, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
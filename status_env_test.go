@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func statusEnvTestStatus() Status {
+	summary := &jsonSummary{
+		Totals: jsonSummaryCounts{Total: 4, Passed: 1, Failed: 2, Skipped: 1},
+		Packages: []*jsonSummaryPackage{
+			{Name: "pkg/a", Counts: jsonSummaryCounts{Total: 3, Passed: 1, Failed: 2}},
+			{Name: "pkg/b", Counts: jsonSummaryCounts{Total: 1, Skipped: 1}},
+			{Name: "pkg/c", Failed: true, FailedBuild: "pkg/c [pkg/c.test]"},
+			{Name: "pkg/d", Failed: true, Counts: jsonSummaryCounts{Total: 1, Known: 1}},
+		},
+		Tests: []*jsonSummaryTest{
+			{Name: "TestA", Package: "pkg/a", Status: "fail"},
+			{Name: "TestA/sub", Package: "pkg/a", Status: "fail"},
+			{Name: "TestB", Package: "pkg/a", Status: "pass", Flaky: true},
+			{Name: "TestC", Package: "pkg/b", Status: "skip"},
+		},
+	}
+	return newStatus(summary, 1500*time.Millisecond, "my report.html")
+}
+
+func TestNewStatus(t *testing.T) {
+	assertions := assert.New(t)
+	status := statusEnvTestStatus()
+	assertions.Equal(Status{
+		Pass:            1,
+		Fail:            2,
+		Total:           4,
+		Skip:            1,
+		Flaky:           1,
+		ElapsedTime:     "1.5s",
+		FailedPackages:  2,
+		PackageFailures: map[string]int{"pkg/a": 2, "pkg/c": 0},
+		Report:          "my report.html",
+		FirstFailure:    "pkg/a.TestA/sub",
+	}, status)
+}
+
+func TestFormatStatus(t *testing.T) {
+	assertions := assert.New(t)
+	status := statusEnvTestStatus()
+
+	content, err := formatStatus(&status, envFormatShell)
+	assertions.Nil(err)
+	assertions.Contains(string(content), "export TOTAL=4\nexport PASS=1\nexport FAIL=2\nexport SKIP=1\nexport KNOWN=0\nexport FLAKY=1\nexport DURATION=1.5s\n")
+	assertions.Contains(string(content), "export REPORT='my report.html'\n")
+
+	content, err = formatStatus(&status, envFormatDotenv)
+	assertions.Nil(err)
+	assertions.Contains(string(content), "FAILED_PACKAGES=2\nPACKAGE_FAILURES=\"pkg/a:2 pkg/c:0\"\nREPORT=\"my report.html\"\nFIRST_FAILURE=pkg/a.TestA/sub\n")
+
+	content, err = formatStatus(&status, envFormatGitHubOutput)
+	assertions.Nil(err)
+	assertions.Contains(string(content), "total=4\n")
+	assertions.Contains(string(content), "report=my report.html\n")
+
+	content, err = formatStatus(&status, envFormatJSON)
+	assertions.Nil(err)
+	assertions.Contains(string(content), `"package_failures": {`)
+
+	_, err = formatStatus(&status, "yaml")
+	assertions.Error(err)
+}
+
+func TestWriteStatus(t *testing.T) {
+	assertions := assert.New(t)
+	status := statusEnvTestStatus()
+	filename := filepath.Join(t.TempDir(), "output")
+	assertions.Nil(writeStatus(&status, filename, envFormatGitHubOutput))
+	assertions.Nil(writeStatus(&status, filename, envFormatGitHubOutput))
+	content, err := os.ReadFile(filename)
+	assertions.Nil(err)
+	assertions.Equal(2, strings.Count(string(content), "total=4\n"))
+
+	assertions.Error(writeStatus(&status, filepath.Join(t.TempDir(), "missing", "status.env"), envFormatShell))
+}
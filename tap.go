@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// summaryNode is a test of the summary together with its subtests. Nodes without a test are
+// packages or parents that did not report a result of their own; failedPackage is set on the
+// node of a package that failed outside of its tests.
+type summaryNode struct {
+	name          string
+	test          *jsonSummaryTest
+	failedPackage *jsonSummaryPackage
+	children      []*summaryNode
+	index         map[string]*summaryNode
+}
+
+func (n *summaryNode) child(name string) *summaryNode {
+	if c, exists := n.index[name]; exists {
+		return c
+	}
+	c := &summaryNode{name: name, index: map[string]*summaryNode{}}
+	n.index[name] = c
+	n.children = append(n.children, c)
+	return c
+}
+
+// failed reports whether the test or, if it has no result of its own, any of its subtests failed.
+func (n *summaryNode) failed() bool {
+	if n.failedPackage != nil {
+		return true
+	}
+	if n.test != nil {
+		return n.test.Status == "fail"
+	}
+	for _, c := range n.children {
+		if c.failed() {
+			return true
+		}
+	}
+	return false
+}
+
+// summaryTree arranges the tests of the summary by package, test and subtest.
+func summaryTree(summary *jsonSummary) *summaryNode {
+	root := &summaryNode{index: map[string]*summaryNode{}}
+	for _, test := range summary.Tests {
+		node := root.child(test.Package)
+		for _, name := range strings.Split(test.Name, "/") {
+			node = node.child(name)
+		}
+		node.test = test
+	}
+	for _, pkg := range summary.failedPackages() {
+		root.child(pkg.Name).failedPackage = pkg
+	}
+	sort.SliceStable(root.children, func(i, j int) bool {
+		return root.children[i].name < root.children[j].name
+	})
+	return root
+}
+
+// writeTAP writes the summary as a TAP version 13 stream. Packages and tests with subtests are
+// written as indented "# Subtest" blocks, durations and messages as YAML diagnostics.
+func writeTAP(w io.Writer, summary *jsonSummary) error {
+	b := &strings.Builder{}
+	b.WriteString("TAP version 13\n")
+	writeTAPChildren(b, summaryTree(summary), "")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeTAPChildren(b *strings.Builder, node *summaryNode, indent string) {
+	fmt.Fprintf(b, "%s1..%d\n", indent, len(node.children))
+	for i, c := range node.children {
+		if len(c.children) > 0 {
+			fmt.Fprintf(b, "%s# Subtest: %s\n", indent, tapEscape(c.name))
+			writeTAPChildren(b, c, indent+"    ")
+		}
+		writeTAPPoint(b, c, i+1, indent)
+	}
+}
+
+func writeTAPPoint(b *strings.Builder, node *summaryNode, number int, indent string) {
+	result := "ok"
+	if node.failed() {
+		result = "not ok"
+	}
+	directive := ""
+	if node.test != nil && node.test.isKnownFailure() {
+		directive = " # TODO " + strings.ReplaceAll(knownFailureText(node.test.KnownFailure), "\n", " ")
+	}
+	if node.test != nil && node.test.Status == "skip" {
+		directive = " # SKIP"
+		if node.test.SkipReason != "" {
+			directive += " " + strings.ReplaceAll(node.test.SkipReason, "\n", " ")
+		}
+	}
+	fmt.Fprintf(b, "%s%s %d - %s%s\n", indent, result, number, tapEscape(node.name), directive)
+	if node.failedPackage != nil {
+		fmt.Fprintf(b, "%s  ---\n", indent)
+		fmt.Fprintf(b, "%s  message: %s\n", indent, yamlQuote(node.failedPackage.failureText()))
+		fmt.Fprintf(b, "%s  output: %s\n", indent, yamlQuote(node.failedPackage.Output))
+		fmt.Fprintf(b, "%s  ...\n", indent)
+	}
+	if node.test == nil {
+		return
+	}
+	fmt.Fprintf(b, "%s  ---\n", indent)
+	fmt.Fprintf(b, "%s  duration_ms: %.3f\n", indent, node.test.Elapsed*1000)
+	if node.test.Location != nil {
+		fmt.Fprintf(b, "%s  at: %s\n", indent, yamlQuote(fmt.Sprintf("%s:%d", node.test.Location.File, node.test.Location.Line)))
+	}
+	if len(node.test.Failures) > 0 {
+		fmt.Fprintf(b, "%s  failures:\n", indent)
+		for _, failure := range node.test.Failures {
+			fmt.Fprintf(b, "%s    - at: %s\n", indent, yamlQuote(fmt.Sprintf("%s:%d", failure.File, failure.Line)))
+			fmt.Fprintf(b, "%s      message: %s\n", indent, yamlQuote(failure.Message))
+		}
+	}
+	fmt.Fprintf(b, "%s  ...\n", indent)
+}
+
+// tapEscape escapes the characters with a special meaning in a TAP description.
+func tapEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "#", `\#`, "\n", " ").Replace(s)
+}
+
+// yamlQuote returns s as a double quoted YAML scalar.
+func yamlQuote(s string) string {
+	return fmt.Sprintf("%q", s)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTAP(t *testing.T) {
+	assertions := assert.New(t)
+	summary := &jsonSummary{
+		Tests: []*jsonSummaryTest{
+			{Name: "TestA", Package: "pkg/a", Status: "fail", Elapsed: 0.5},
+			{Name: "TestA/sub#1", Package: "pkg/a", Status: "fail", Elapsed: 0.25,
+				Failures: []failureMessage{{File: "a_test.go", Line: 12, Message: "want 1\ngot 2"}}},
+			{Name: "TestA/sub#2", Package: "pkg/a", Status: "pass"},
+			{Name: "TestB", Package: "pkg/b", Status: "skip", SkipReason: "needs docker",
+				Location: &jsonSummaryLocation{File: "b_test.go", Line: 3}},
+		},
+	}
+	buffer := &bytes.Buffer{}
+	assertions.Nil(writeTAP(buffer, summary))
+	assertions.Equal(`TAP version 13
+1..2
+# Subtest: pkg/a
+    1..1
+    # Subtest: TestA
+        1..2
+        not ok 1 - sub\#1
+          ---
+          duration_ms: 250.000
+          failures:
+            - at: "a_test.go:12"
+              message: "want 1\ngot 2"
+          ...
+        ok 2 - sub\#2
+          ---
+          duration_ms: 0.000
+          ...
+    not ok 1 - TestA
+      ---
+      duration_ms: 500.000
+      ...
+not ok 1 - pkg/a
+# Subtest: pkg/b
+    1..1
+    ok 1 - TestB # SKIP needs docker
+      ---
+      duration_ms: 0.000
+      at: "b_test.go:3"
+      ...
+ok 2 - pkg/b
+`, buffer.String())
+}
+
+func TestWriteTAPPackageFailures(t *testing.T) {
+	assertions := assert.New(t)
+	buffer := &bytes.Buffer{}
+	assertions.Nil(writeTAP(buffer, buildFailureSummary(t)))
+	assertions.Equal(`TAP version 13
+1..3
+not ok 1 - example.com/bf/broken
+  ---
+  message: "build failed: example.com/bf/broken [example.com/bf/broken.test]"
+  output: "# example.com/bf/broken [example.com/bf/broken.test]\nbroken/broken_test.go:5:33: undefined: undefinedCall\nFAIL\texample.com/bf/broken [build failed]\n"
+  ...
+# Subtest: example.com/bf/good
+    1..1
+    ok 1 - TestGood
+      ---
+      duration_ms: 0.000
+      ...
+ok 2 - example.com/bf/good
+not ok 3 - example.com/bf/mainfail
+  ---
+  message: "package failed outside of its tests"
+  output: "setup failed\nFAIL\texample.com/bf/mainfail\t0.002s\n"
+  ...
+`, buffer.String())
+}
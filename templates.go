@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+)
+
+// templateFuncs are the helper functions available in the HTML report template and in templates
+// passed with --template.
+var templateFuncs = template.FuncMap{
+	// seconds formats a duration in seconds, e.g. 1.5 as "1.50s".
+	"seconds": func(elapsed float64) string {
+		return fmt.Sprintf("%.2fs", elapsed)
+	},
+	// percent returns part as a percentage of total, e.g. "75.0%".
+	"percent": func(part, total int) string {
+		if total == 0 {
+			return "0.0%"
+		}
+		return fmt.Sprintf("%.1f%%", float64(part)*100/float64(total))
+	},
+	// status returns pass, fail, skip or known for a test.
+	"status": func(status *testStatus) string {
+		if status.isKnownFailure() {
+			return "known"
+		}
+		return status.statusText()
+	},
+	"join": strings.Join,
+	// ansiPalette returns the CSS classes of the 256 color palette of the test outputs.
+	"ansiPalette": ansiPaletteCSS,
+	// add returns the sum of the numbers, e.g. of the lengths of two lists.
+	"add": func(numbers ...int) int {
+		sum := 0
+		for _, n := range numbers {
+			sum += n
+		}
+		return sum
+	},
+}
+
+// newCSPNonce returns a random nonce for the Content-Security-Policy of the report. Only the
+// scripts and stylesheets of the template carry it, so scripts and styles injected into the report
+// are not applied.
+func newCSPNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(nonce), nil
+}
+
+// newReportTemplate parses the HTML report template, which is the embedded one unless text is
+// not empty.
+func newReportTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = string(testReportHTMLTemplateStr)
+	}
+	return template.New("report.html.template").Funcs(templateFuncs).Parse(text)
+}
+
+// readCustomTemplates reads the files of --template and --css into the template data. The
+// template is parsed right away so that errors are reported before any output is written.
+func readCustomTemplates(tmplData *templateData, flags *cmdFlags) error {
+	if flags.templateFlag != "" {
+		content, err := os.ReadFile(flags.templateFlag)
+		if err != nil {
+			return fmt.Errorf("failed to read template file: %w", err)
+		}
+		if _, err := newReportTemplate(string(content)); err != nil {
+			return fmt.Errorf("invalid template file %s: %w", flags.templateFlag, err)
+		}
+		tmplData.htmlTemplate = string(content)
+	}
+	if flags.cssFlag != "" {
+		content, err := os.ReadFile(flags.cssFlag)
+		if err != nil {
+			return fmt.Errorf("failed to read css file: %w", err)
+		}
+		if strings.Contains(strings.ToLower(string(content)), "</style") {
+			return fmt.Errorf("invalid css file %s: it must not close the style element", flags.cssFlag)
+		}
+		tmplData.CustomCSS = template.CSS(content)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadCustomTemplates(t *testing.T) {
+	assertions := assert.New(t)
+	dir := t.TempDir()
+	templateFile := filepath.Join(dir, "report.tmpl")
+	cssFile := filepath.Join(dir, "report.css")
+	assertions.Nil(os.WriteFile(templateFile, []byte(`<style>{{.CustomCSS}}</style><h1>{{.ReportTitle}}</h1>{{percent .NumOfTestPassed .NumOfTests}}
+{{range .TestResults}}{{range .TestResults}}{{.TestName}} {{status .}} {{seconds .ElapsedTime}}
+{{end}}{{end}}`), 0644))
+	assertions.Nil(os.WriteFile(cssFile, []byte("body { color: teal; }"), 0644))
+
+	tmplData := &templateData{ReportTitle: "<branded>", NumOfTestPassed: 1, NumOfTests: 2}
+	assertions.Nil(readCustomTemplates(tmplData, &cmdFlags{templateFlag: templateFile, cssFlag: cssFile}))
+	tmplData.TestResults = groupTests([]*testStatus{
+		{TestName: "TestA", Passed: true, ElapsedTime: 1.5},
+		{TestName: "TestB", KnownFailure: &knownFailure{Test: "TestB"}},
+	}, groupByChunk, 20)
+	buffer := &bytes.Buffer{}
+	assertions.Nil(renderHTMLReport(buffer, tmplData))
+	assertions.Equal(`<style>body { color: teal; }</style><h1>&lt;branded&gt;</h1>50.0%
+TestA pass 1.50s
+TestB known 0.00s
+`, buffer.String())
+}
+
+func TestReadCustomTemplatesIfInvalid(t *testing.T) {
+	assertions := assert.New(t)
+	dir := t.TempDir()
+	templateFile := filepath.Join(dir, "report.tmpl")
+	cssFile := filepath.Join(dir, "report.css")
+	assertions.Nil(os.WriteFile(templateFile, []byte("{{.ReportTitle"), 0644))
+	assertions.Nil(os.WriteFile(cssFile, []byte("</STYLE><script>alert(1)</script>"), 0644))
+
+	err := readCustomTemplates(&templateData{}, &cmdFlags{templateFlag: templateFile})
+	assertions.ErrorContains(err, "invalid template file")
+	err = readCustomTemplates(&templateData{}, &cmdFlags{cssFlag: cssFile})
+	assertions.ErrorContains(err, "must not close the style element")
+	err = readCustomTemplates(&templateData{}, &cmdFlags{templateFlag: filepath.Join(dir, "missing.tmpl")})
+	assertions.ErrorContains(err, "failed to read template file")
+}
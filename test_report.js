@@ -1,15 +1,32 @@
 /**
  * @typedef TestStatus
+ * @property {string} ID The id of the test, derived from its package and name.
  * @property {string} TestName
  * @property {string} Package
  * @property {number} ElapsedTime
- * @property {Array.<string>} Output
+ * @property {number} OutputBlock The output block with the output of the test.
+ * @property {number} OutputIndex The position of the output in its output block.
  * @property {Array.<string>} Screenshots
  * @property {boolean} Passed
  * @property {boolean} Skipped
+ * @property {Array.<string>} History
+ * @property {KnownFailure} KnownFailure
+ * @property {number} BaselineElapsed
+ * @property {boolean} Slower
+ * @property {number} TruncatedBytes The bytes of output left out of the report.
+ * @property {string} FullOutput The file with the full output, if the output is truncated.
  */
 class TestStatus { }
 
+/**
+ * @typedef KnownFailure
+ * @property {string} Package
+ * @property {string} Test
+ * @property {string} Reason
+ * @property {string} Ticket
+ */
+class KnownFailure { }
+
 /**
  * @typedef TestGroupData
  * @type {object}
@@ -25,6 +42,18 @@ class TestGroupData { }
  */
 class TestResults extends Array { }
 
+/**
+ * @typedef HistoryRun
+ * @property {string} Timestamp
+ * @property {string} Commit
+ * @property {number} Elapsed
+ * @property {number} PassRate
+ * @property {number} Passed
+ * @property {number} Failed
+ * @property {number} Skipped
+ */
+class HistoryRun { }
+
 /**
  * @typedef SelectedItems
  * @property {HTMLElement|EventTarget} testResults
@@ -35,8 +64,16 @@ class SelectedItems { }
 /**
  * @typedef GoTestReportElements
  * @property {TestResults} data
+ * @property {Array.<HistoryRun>} history
+ * @property {HTMLElement} trendChartsElem
  * @property {HTMLElement} testResultsElem
  * @property {HTMLElement} testGroupListElem
+ * @property {Array.<string>} outputBlocks The compressed outputs of the tests, by output block.
+ * @property {Array.<string>} outputFiles The URLs of the outputs of the tests by output block, if
+ * they are not embedded.
+ * @property {HTMLInputElement} searchInputElem
+ * @property {HTMLInputElement} searchRegexElem
+ * @property {HTMLElement} searchCountElem
  */
 class GoTestReportElements { }
 
@@ -47,11 +84,136 @@ class GoTestReportElements { }
  * @returns {{testResultsClickHandler: testResultsClickHandler}}
  * @constructor
  */
-var testCaseFilter = ["PASS", "FAIL", "SKIP"]
+var testCaseFilter = ["PASS", "FAIL", "SKIP", "KNOWN"]
+var testCaseSearch = ""
+var testCaseSearchRegex = false
+var testCasePackage = ""
 
+/**
+ * Lists the tests of all groups that have one of the statuses.
+ * @param {Array.<string>} status
+ */
 function Filter(status) {
   testCaseFilter = status
-  document.getElementById('0').click()
+  updateQueryString()
+  document.getElementById('testResults').click()
+}
+
+/**
+ * Lists the tests of all groups whose name, package, filename or output contain the text, or match
+ * it as a regular expression. Matching ignores case.
+ * @param {string} text
+ * @param {boolean} regex
+ */
+function Search(text, regex) {
+  testCaseSearch = text
+  testCaseSearchRegex = regex
+  updateQueryString()
+  document.getElementById('testResults').click()
+}
+
+/**
+ * Lists the tests of all groups in the package or in the packages below it. An empty package lists
+ * the tests of all packages.
+ * @param {string} pkg
+ */
+function FilterPackage(pkg) {
+  testCasePackage = pkg
+  document.querySelectorAll('.packageTree .packageName').forEach((elem) => {
+    elem.classList.toggle('selected', elem.getAttribute('data-path') === pkg)
+  })
+  updateQueryString()
+  document.getElementById('testResults').click()
+}
+
+/**
+ * Reports whether the test is in the package selected in the package tree or below it.
+ * @param {TestStatus} testResult
+ * @returns {boolean}
+ */
+function matchesPackage(testResult) {
+  return testCasePackage === ''
+    || testResult.Package === testCasePackage
+    || testResult.Package.startsWith(testCasePackage + '/')
+}
+
+/**
+ * Returns a function reporting whether a test matches the search, or null if the search is an
+ * invalid regular expression.
+ * @param {function(testResult: TestStatus): string} output Returns the output of a test.
+ * @returns {?function(testResult: TestStatus): boolean}
+ */
+function testSearchMatcher(output) {
+  if (testCaseSearch === '') {
+    return () => true
+  }
+  let match
+  if (testCaseSearchRegex) {
+    let re
+    try {
+      re = new RegExp(testCaseSearch, 'i')
+    } catch (e) {
+      return null
+    }
+    match = (text) => re.test(text)
+  } else {
+    const search = testCaseSearch.toLowerCase()
+    match = (text) => text.toLowerCase().includes(search)
+  }
+  return (testResult) => match(testResult.TestName)
+    || match(testResult.Package)
+    || match(testResult.TestFileName || '')
+    || match(output(testResult))
+}
+
+/**
+ * Keeps the search and the status filter in the query string, so that filtered views can be
+ * shared.
+ */
+function updateQueryString() {
+  const params = new URLSearchParams(window.location.search)
+  if (testCaseSearch !== '') {
+    params.set('q', testCaseSearch)
+  } else {
+    params.delete('q')
+  }
+  if (testCaseSearchRegex) {
+    params.set('regex', '1')
+  } else {
+    params.delete('regex')
+  }
+  if (testCasePackage !== '') {
+    params.set('pkg', testCasePackage)
+  } else {
+    params.delete('pkg')
+  }
+  if (testCaseFilter.length < 4) {
+    params.set('status', testCaseFilter.join(','))
+  } else {
+    params.delete('status')
+  }
+  const query = params.toString()
+  try {
+    window.history.replaceState(null, '', window.location.pathname + (query ? '?' + query : '') + window.location.hash)
+  } catch (e) {
+    // some browsers do not allow changing the URL of local files
+  }
+}
+
+/**
+ * Restores the search and the status filter from the query string.
+ */
+function readQueryString() {
+  const params = new URLSearchParams(window.location.search)
+  testCaseSearch = params.get('q') || ''
+  testCaseSearchRegex = params.get('regex') === '1'
+  testCasePackage = params.get('pkg') || ''
+  if (params.has('status')) {
+    const status = params.get('status').split(',').filter((s) => ["PASS", "FAIL", "SKIP", "KNOWN"].includes(s))
+    if (status.length > 0) {
+      testCaseFilter = status
+    }
+  }
 }
 
 window.GoTestReport = function (elements) {
@@ -66,6 +228,523 @@ window.GoTestReport = function (elements) {
     }
     return event
   }
+
+  /**
+   * Returns an SVG line chart of the values, one point per run.
+   * @param {string} title
+   * @param {Array.<HistoryRun>} history
+   * @param {function(run: HistoryRun): number} value
+   * @param {function(value: number): string} format
+   * @param {number} max The value shown at the top of the chart.
+   * @returns {string}
+   */
+  function trendChart(title, history, value, format, max) {
+    const width = 320, height = 80, padding = 6
+    const step = history.length > 1 ? (width - 2 * padding) / (history.length - 1) : 0
+    const points = history.map((run, i) => {
+      const x = history.length > 1 ? padding + i * step : width / 2
+      const y = height - padding - (max > 0 ? value(run) / max : 0) * (height - 2 * padding)
+      return { x: x.toFixed(1), y: y.toFixed(1), run: run }
+    })
+    const circles = points.map(p => {
+      const label = `${p.run.Timestamp}${p.run.Commit ? ' ' + p.run.Commit.substring(0, 8) : ''}: ${format(value(p.run))}`
+      return `<circle cx="${p.x}" cy="${p.y}" r="3" class="${p.run.Failed > 0 ? 'failed' : ''}"><title>${escapeHTML(label)}</title></circle>`
+    }).join('')
+    const last = history[history.length - 1]
+    return `<div class="trendChart">${title}: <strong>${format(value(last))}</strong>
+      <svg width="${width}" height="${height}" viewBox="0 0 ${width} ${height}">
+        <polyline points="${points.map(p => p.x + ',' + p.y).join(' ')}"/>${circles}
+      </svg></div>`
+  }
+
+  /**
+   * Renders the pass rate and duration trends of the recent runs.
+   * @param {Array.<HistoryRun>} history
+   * @param {HTMLElement} trendChartsElem
+   */
+  function renderTrendCharts(history, trendChartsElem) {
+    if (trendChartsElem == null || history == null || history.length === 0) {
+      return
+    }
+    const maxElapsed = Math.max(...history.map(run => run.Elapsed))
+    trendChartsElem.innerHTML =
+      trendChart(`Pass rate (last ${history.length} runs)`, history, run => run.PassRate, v => `${v.toFixed(1)}%`, 100) +
+      trendChart(`Duration (last ${history.length} runs)`, history, run => run.Elapsed, v => `${v.toFixed(2)}s`, maxElapsed)
+  }
+
+  /**
+   * Returns the recent outcomes of a test, oldest first.
+   * @param {TestStatus} testResult
+   * @returns {string}
+   */
+  function sparkline(testResult) {
+    if (testResult.History == null) {
+      return ''
+    }
+    const outcomes = testResult.History.map(outcome => `<span class="${escapeHTML(outcome)}" title="${escapeHTML(outcome || 'not run')}"></span>`)
+    return `<span class="sparkline">${outcomes.join('')}</span>`
+  }
+
+  /**
+   * Returns the duration of a test, highlighted if it got slower than its baseline.
+   * @param {TestStatus} testResult
+   * @returns {string}
+   */
+  function elapsedTime(testResult) {
+    if (testResult.Slower) {
+      return `<span class="slower" title="baseline ${escapeHTML(testResult.BaselineElapsed)}s">${escapeHTML(testResult.ElapsedTime)}s </span>`
+    }
+    return `<span >${escapeHTML(testResult.ElapsedTime)}s </span>`
+  }
+
+  /**
+   * Returns the element showing the output and the details of a test. The output is filled in
+   * once its block is decompressed.
+   * @param {TestStatus} testStatus
+   * @returns {HTMLDivElement}
+   */
+  function testOutputElement(testStatus) {
+    const testOutputDiv = document.createElement('div')
+    testOutputDiv.classList.add('testOutput')
+    const consolePre = document.createElement('pre')
+    consolePre.classList.add('console')
+    const testDetailDiv = document.createElement('div')
+    testDetailDiv.classList.add('testDetail')
+    const packageNameDiv = document.createElement('div')
+    packageNameDiv.classList.add('package')
+    packageNameDiv.innerHTML = `<strong>Package:</strong> `
+    const packageLink = document.createElement('a')
+    packageLink.classList.add('packageLink')
+    packageLink.setAttribute('href', '?pkg=' + encodeURIComponent(testStatus.Package))
+    packageLink.setAttribute('data-package', testStatus.Package)
+    packageLink.setAttribute('title', 'List the tests of the package')
+    packageLink.appendChild(document.createTextNode(testStatus.Package))
+    packageNameDiv.append(packageLink)
+    const screenshotDiv = document.createElement('div')
+    screenshotDiv.classList.add('package')
+    if (testStatus.Screenshots && testStatus.Screenshots.length > 0) {
+      const ul = document.createElement('ul')
+      testStatus.Screenshots.filter(isSafeURL).forEach(element => {
+        var li = document.createElement('li');
+        var link = document.createElement('a');
+        link.setAttribute("href", element);
+        link.setAttribute("target", "_blank");
+        var linkText = document.createTextNode(element);
+        link.appendChild(linkText);
+        li.appendChild(link);
+        ul.appendChild(li);
+      });
+      screenshotDiv.innerHTML = `<strong>Screenshots:</strong>`
+      screenshotDiv.append(ul)
+    }
+    const testFileNameDiv = document.createElement('div')
+    testFileNameDiv.classList.add('filename')
+    if (testStatus.TestFileName.trim() === "") {
+      testFileNameDiv.innerHTML = `<strong>Filename:</strong> n/a &nbsp;&nbsp;`
+    } else {
+      testFileNameDiv.innerHTML = `<strong>Filename:</strong> ${escapeHTML(testStatus.TestFileName)} &nbsp;&nbsp;`
+      testFileNameDiv.innerHTML += `<strong>Line:</strong> ${escapeHTML(testStatus.TestFunctionDetail.Line)} `
+      testFileNameDiv.innerHTML += `<strong>Col:</strong> ${escapeHTML(testStatus.TestFunctionDetail.Col)}`
+    }
+    const knownFailureDiv = document.createElement('div')
+    knownFailureDiv.classList.add('knownFailure')
+    if (testStatus.KnownFailure != null) {
+      const knownFailure = /**@type {KnownFailure}*/ testStatus.KnownFailure
+      knownFailureDiv.innerHTML = `<strong>Known failure:</strong> `
+      knownFailureDiv.append(document.createTextNode(knownFailure.Reason || 'no reason given'))
+      if (knownFailure.Ticket) {
+        knownFailureDiv.append(document.createTextNode(' '))
+        if (/^https?:\/\//.test(knownFailure.Ticket)) {
+          const link = document.createElement('a')
+          link.setAttribute('href', knownFailure.Ticket)
+          link.setAttribute('target', '_blank')
+          link.appendChild(document.createTextNode(knownFailure.Ticket))
+          knownFailureDiv.append(link)
+        } else {
+          knownFailureDiv.append(document.createTextNode(knownFailure.Ticket))
+        }
+      }
+    }
+    const truncatedDiv = document.createElement('div')
+    truncatedDiv.classList.add('truncated')
+    if (testStatus.TruncatedBytes > 0) {
+      truncatedDiv.innerHTML = `<strong>Output truncated:</strong> ${escapeHTML(testStatus.TruncatedBytes)} bytes left out `
+      if (testStatus.FullOutput && isSafeURL(testStatus.FullOutput)) {
+        const link = document.createElement('a')
+        link.setAttribute('href', testStatus.FullOutput)
+        link.setAttribute('target', '_blank')
+        link.appendChild(document.createTextNode('full output'))
+        truncatedDiv.append(link)
+      }
+    }
+    testDetailDiv.insertAdjacentElement('beforeend', knownFailureDiv)
+    testDetailDiv.insertAdjacentElement('beforeend', truncatedDiv)
+    testDetailDiv.insertAdjacentElement('beforeend', screenshotDiv)
+    testDetailDiv.insertAdjacentElement('beforeend', packageNameDiv)
+    testDetailDiv.insertAdjacentElement('beforeend', testFileNameDiv)
+    testOutputDiv.insertAdjacentElement('afterbegin', consolePre)
+    testOutputDiv.insertAdjacentElement('beforeend', testDetailDiv)
+
+    if (testStatus.Passed) {
+      consolePre.classList.remove('skipped')
+      consolePre.classList.remove('failed')
+    } else if (testStatus.Skipped) {
+      consolePre.classList.add('skipped')
+      consolePre.classList.remove('failed')
+    } else if (testStatus.KnownFailure != null) {
+      consolePre.classList.remove('skipped')
+      consolePre.classList.add('known')
+    } else {
+      consolePre.classList.remove('skipped')
+      consolePre.classList.add('failed')
+    }
+    // the outputs are escaped HTML with spans for the ANSI colors
+    const output = outputHTML(testStatus)
+    if (output !== undefined) {
+      consolePre.innerHTML = output
+      markLinkedFailure(testStatus, consolePre)
+    } else {
+      consolePre.textContent = 'loading output...'
+      testOutput(testStatus).then(output => {
+        consolePre.innerHTML = output
+        markLinkedFailure(testStatus, consolePre)
+        scheduleRender()
+      })
+    }
+    return testOutputDiv
+  }
+
+  const outputBlocks = /**@type {Array.<Promise.<Array.<string>>>}*/ []
+  const loadedOutputBlocks = /**@type {Array.<Array.<string>>}*/ []
+  const outputTextBlocks = /**@type {Array.<Array.<string>>}*/ []
+  let allOutputs = /**@type {?Promise.<void>}*/ null
+  let allOutputsLoaded = false
+
+  /**
+   * Returns the outputs of the tests of a block, decompressing or fetching the block on first use.
+   * @param {number} block
+   * @returns {Promise.<Array.<string>>}
+   */
+  function outputBlock(block) {
+    if (outputBlocks[block] === undefined) {
+      const outputs = elements.outputFiles
+        ? fetchJSON(elements.outputFiles[block])
+        : decompressBase64(elements.outputBlocks[block]).then(JSON.parse)
+      outputBlocks[block] = outputs.then(outputs => {
+        loadedOutputBlocks[block] = outputs
+        return outputs
+      })
+    }
+    return outputBlocks[block]
+  }
+
+  /**
+   * Returns the output of a test as HTML if its block is loaded.
+   * @param {TestStatus} testStatus
+   * @returns {string|undefined}
+   */
+  function outputHTML(testStatus) {
+    const outputs = loadedOutputBlocks[testStatus.OutputBlock]
+    return outputs === undefined ? undefined : outputs[testStatus.OutputIndex]
+  }
+
+  /**
+   * Returns the output of a test as plain text, which the search matches, if its block is loaded.
+   * @param {TestStatus} testStatus
+   * @returns {string|undefined}
+   */
+  function outputText(testStatus) {
+    const block = testStatus.OutputBlock
+    if (outputTextBlocks[block] === undefined) {
+      if (loadedOutputBlocks[block] === undefined) {
+        return undefined
+      }
+      outputTextBlocks[block] = loadedOutputBlocks[block].map(htmlText)
+    }
+    return outputTextBlocks[block][testStatus.OutputIndex]
+  }
+
+  /**
+   * Returns the output of a test.
+   * @param {TestStatus} testStatus
+   * @returns {Promise.<string>}
+   */
+  function testOutput(testStatus) {
+    return outputBlock(testStatus.OutputBlock).then(outputs => outputs[testStatus.OutputIndex])
+  }
+
+  /**
+   * Loads the outputs of all tests, which the search needs.
+   * @returns {Promise.<void>}
+   */
+  function loadAllOutputs() {
+    if (allOutputs == null) {
+      const blocks = elements.outputFiles || elements.outputBlocks || []
+      allOutputs = Promise.all(blocks.map((_, block) => outputBlock(block))).then(() => {
+        allOutputsLoaded = true
+      })
+    }
+    return allOutputs
+  }
+
+  //+------------------------+
+  //|    virtual test list   |
+  //+------------------------+
+  // Only the rows of the test list that are scrolled into view are in the DOM. Collapsed rows
+  // have the height of the first rendered row, expanded rows the height they had when rendered.
+  const defaultRowHeight = 38
+  const list = {
+    /** @type {Array.<{groupId: number, index: number, testResult: TestStatus}>} */
+    rows: [],
+    /** @type {Map.<number, number>} the heights of the expanded rows by row index, 0 if not measured yet */
+    expanded: new Map(),
+    rowHeight: 0,
+    renderScheduled: false
+  }
+
+  function rowHeightOf(rowIndex) {
+    const rowHeight = list.rowHeight || defaultRowHeight
+    return list.expanded.has(rowIndex) ? (list.expanded.get(rowIndex) || rowHeight * 4) : rowHeight
+  }
+
+  /**
+   * Returns the offset of a row from the top of the list.
+   * @param {number} rowIndex
+   * @returns {number}
+   */
+  function rowOffset(rowIndex) {
+    const rowHeight = list.rowHeight || defaultRowHeight
+    let top = rowIndex * rowHeight
+    list.expanded.forEach((height, i) => {
+      if (i < rowIndex) {
+        top += rowHeightOf(i) - rowHeight
+      }
+    })
+    return top
+  }
+
+  /**
+   * Returns the index of the row at the offset from the top of the list.
+   * @param {number} offset
+   * @returns {number}
+   */
+  function rowAt(offset) {
+    let low = 0
+    let high = list.rows.length - 1
+    while (low < high) {
+      const mid = Math.ceil((low + high) / 2)
+      if (rowOffset(mid) <= offset) {
+        low = mid
+      } else {
+        high = mid - 1
+      }
+    }
+    return low
+  }
+
+  function scheduleRender() {
+    if (!list.renderScheduled) {
+      list.renderScheduled = true
+      window.requestAnimationFrame(() => {
+        list.renderScheduled = false
+        renderTestList()
+      })
+    }
+  }
+
+  /**
+   * Returns the HTML of a row of the test list.
+   * @param {number} rowIndex
+   * @returns {string}
+   */
+  function testRowHTML(rowIndex) {
+    const row = list.rows[rowIndex]
+    const testResult = /**@type {TestStatus}*/ row.testResult
+    const testPassed = /**@type {boolean}*/ testResult.Passed
+    const testSkipped = /**@type {boolean}*/ testResult.Skipped
+    const testKnown = /**@type {boolean}*/ !testPassed && !testSkipped && testResult.KnownFailure != null
+    const testPassedStatus = /**@type {string}*/ (testPassed) ? '' : (testSkipped ? 'skipped' : (testKnown ? 'known' : 'failed'))
+    const testStatus = /**@type {string}*/ (testPassed) ? 'PASS' : (testSkipped ? 'SKIP' : (testKnown ? 'KNOWN' : 'FAIL'))
+    const knownFailurePassed = /**@type {string}*/ (testPassed && testResult.KnownFailure != null) ? '<span class="knownFailurePassed" title="listed as a known failure but passed">known failure passed</span>' : ''
+    return `<div id="${escapeHTML(testResult.ID)}" class="testGroupRow ${testPassedStatus}" data-groupid="${row.groupId}" data-index="${row.index}" data-row="${rowIndex}">
+        <span class="testTextStatus ${testPassedStatus}">${testStatus}</span>
+        <span class="testStatus ${testPassedStatus}">${(testPassed) ? '&check' : (testSkipped ? '&dash' : (testKnown ? '&excl' : '&cross'))};</span>
+        <span class="testTitle">${escapeHTML(testResult.TestName)}</span>
+        <span class="testDuration">${knownFailurePassed}${sparkline(testResult)}<a class="shareLink" href="?testcase=${encodeURIComponent(testResult.ID)}" title="Copy the link to this test">🔗</a>${elapsedTime(testResult)}⏱</span>
+      </div>`
+  }
+
+  /**
+   * Renders the rows of the test list that are scrolled into view, and measures them.
+   */
+  function renderTestList() {
+    const listElem = elements.testGroupListElem
+    if (list.rows.length === 0) {
+      return
+    }
+    const viewHeight = listElem.clientHeight || window.innerHeight
+    const scrollTop = listElem.scrollTop
+    const first = rowAt(Math.max(0, scrollTop - viewHeight / 2))
+    const last = Math.min(list.rows.length, rowAt(scrollTop + viewHeight * 1.5) + 1)
+    const top = rowOffset(first)
+    const total = rowOffset(list.rows.length)
+    let rowsHTML = ''
+    for (let i = first; i < last; i++) {
+      rowsHTML += testRowHTML(i)
+    }
+    listElem.innerHTML = `<div class="virtualList"><div class="virtualRows">${rowsHTML}</div></div>`
+    // the Content-Security-Policy does not allow style attributes, only styles set from the script
+    listElem.querySelector('.virtualList').style.height = `${total}px`
+    listElem.querySelector('.virtualRows').style.top = `${top}px`
+
+    let changed = false
+    listElem.querySelectorAll('.testGroupRow').forEach(rowElem => {
+      const rowIndex = Number(rowElem.getAttribute('data-row'))
+      if (list.expanded.has(rowIndex)) {
+        rowElem.insertAdjacentElement('beforeend', testOutputElement(list.rows[rowIndex].testResult))
+        const height = rowElem.offsetHeight
+        if (height > 0 && height !== list.expanded.get(rowIndex)) {
+          list.expanded.set(rowIndex, height)
+          changed = true
+        }
+      } else if (list.rowHeight === 0 && rowElem.offsetHeight > 0) {
+        list.rowHeight = rowElem.offsetHeight
+        changed = true
+      }
+    })
+    if (changed) {
+      scheduleRender()
+    }
+  }
+
+  /** @type {?{id: string, failure: number}} the failure line of the test linked to */
+  let linkedFailure = null
+
+  /**
+   * Highlights the failure line linked to in the output of a test.
+   * @param {TestStatus} testStatus
+   * @param {HTMLElement} consolePre
+   */
+  function markLinkedFailure(testStatus, consolePre) {
+    if (linkedFailure == null || linkedFailure.id !== testStatus.ID) {
+      return
+    }
+    const link = consolePre.querySelector(`#${testStatus.ID}-failure-${linkedFailure.failure}`)
+    if (link != null) {
+      link.classList.add('linked')
+    }
+  }
+
+  /**
+   * Returns the group and the test with the id, or with the name for links to tests by name.
+   * @param {string} testID
+   * @returns {?{groupId: number, testResult: TestStatus}}
+   */
+  function findTest(testID) {
+    for (const match of [t => t.ID === testID, t => t.TestName === testID]) {
+      for (let groupId = 0; groupId < elements.data.length; groupId++) {
+        const testResult = (elements.data[groupId].TestResults || []).find(match)
+        if (testResult !== undefined) {
+          return { groupId: groupId, testResult: testResult }
+        }
+      }
+    }
+    return null
+  }
+
+  /**
+   * Clears the status filter, the search and the package selection.
+   */
+  function clearFilters() {
+    testCaseFilter = ["PASS", "FAIL", "SKIP", "KNOWN"]
+    testCaseSearch = ''
+    testCaseSearchRegex = false
+    testCasePackage = ''
+    if (elements.searchInputElem) {
+      elements.searchInputElem.value = ''
+      elements.searchRegexElem.checked = false
+    }
+    document.querySelectorAll('.packageTree .packageName').forEach((elem) => {
+      elem.classList.toggle('selected', elem.getAttribute('data-path') === '')
+    })
+    updateQueryString()
+  }
+
+  /**
+   * Lists the group of a test, expands the test and its parent tests, scrolls to it and returns
+   * whether the test exists. The filters are cleared if they hide the test.
+   * @param {string} testID The id of the test, or its name.
+   * @param {?number} failure The failure line of the test to highlight, if any.
+   * @returns {Promise.<boolean>}
+   */
+  function showTest(testID, failure) {
+    if (testCaseSearch !== '' && !allOutputsLoaded) {
+      // the search matches the outputs, which are decompressed first
+      return loadAllOutputs().then(() => showTest(testID, failure))
+    }
+    const found = findTest(testID)
+    if (found == null) {
+      return Promise.resolve(false)
+    }
+    const groupElem = document.getElementById(String(found.groupId))
+    const listGroup = () => {
+      goTestReport.testResultsClickHandler(groupElem, false, elements.data, selectedItems, goTestReport.testGroupListHandler)
+      return list.rows.findIndex(row => row.testResult === found.testResult)
+    }
+    let rowIndex = listGroup()
+    if (rowIndex < 0) {
+      clearFilters()
+      rowIndex = listGroup()
+    }
+    const testResult = found.testResult
+    const ancestors = testResult.TestName.split('/').map((name, i, names) => names.slice(0, i + 1).join('/'))
+    list.expanded = new Map()
+    list.rows.forEach((row, i) => {
+      if (row.testResult.Package === testResult.Package && ancestors.includes(row.testResult.TestName)) {
+        list.expanded.set(i, 0)
+      }
+    })
+    linkedFailure = failure == null ? null : { id: testResult.ID, failure: failure }
+    // the expanded rows are measured while rendering, which moves the test
+    for (let i = 0; i < 2; i++) {
+      elements.testGroupListElem.scrollTop = rowOffset(rowIndex)
+      renderTestList()
+    }
+    if (linkedFailure != null) {
+      testOutput(testResult).then(() => {
+        renderTestList()
+        const link = document.getElementById(`${testResult.ID}-failure-${failure}`)
+        if (link != null) {
+          link.scrollIntoView({ block: 'center' })
+        }
+      })
+    }
+    return Promise.resolve(true)
+  }
+
+  /**
+   * Follows a link of the test list without reloading the report: a share link copies the URL of
+   * the test, a package link lists the tests of the package and a failure link highlights the
+   * failure line.
+   * @param {HTMLAnchorElement} link
+   */
+  function followLink(link) {
+    if (link.classList.contains('shareLink')) {
+      copyTestcaseURL(link.href)
+    } else if (link.classList.contains('packageLink')) {
+      FilterPackage(link.getAttribute('data-package'))
+    } else if (link.classList.contains('failureLink')) {
+      const params = new URLSearchParams(link.getAttribute('href'))
+      linkedFailure = { id: params.get('testcase'), failure: Number(params.get('failure')) }
+      try {
+        window.history.replaceState(null, '', link.href)
+      } catch (e) {
+        // some browsers do not allow changing the URL of local files
+      }
+      renderTestList()
+    }
+  }
+
   const goTestReport = {
     /**
      * Invoked when a user clicks on one of the test group div elements.
@@ -81,132 +760,111 @@ window.GoTestReport = function (elements) {
       selectedItems,
       testGroupListHandler) {
 
-      if (target.classList.contains('testResultGroup') === false) {
+      // a click on the container itself, e.g. from Filter, lists the tests of all groups
+      const allGroups = /**@type {boolean}*/ target === elements.testResultsElem
+      if (!allGroups && target.classList.contains('testResultGroup') === false) {
+        return
+      }
+      if (!allGroups && ((target.id === undefined)
+        || (data[target.id] === undefined)
+        || (data[target.id]['TestResults'] === undefined))) {
+        return
+      }
+      if (testCaseSearch !== '' && !allOutputsLoaded) {
+        // the search matches the outputs, which are decompressed first
+        elements.testGroupListElem.innerHTML = '<div class="listMessage">Searching...</div>'
+        loadAllOutputs().then(() => goTestReport.testResultsClickHandler(target, shiftKey, data, selectedItems, testGroupListHandler))
         return
       }
       if (selectedItems.testResults != null) {
         let testResultsElement = /**@type {HTMLElement}*/ selectedItems.testResults
         testResultsElement.classList.remove("selected")
         testResultsElement.style.backgroundColor = selectedItems.selectedTestGroupColor
+        selectedItems.testResults = null
       }
-      const testGroupId = /**@type {number}*/ target.id
-      if ((target.id === undefined)
-        || (data[testGroupId] === undefined)
-        || (data[testGroupId]['TestResults'] === undefined)) {
-        return
+      const testGroupIds = /**@type {Array.<number>}*/ allGroups ? (data || []).map((group, i) => i) : [Number(target.id)]
+      let numOfTests = /**@type {number}*/ 0
+      const matchesSearch = testSearchMatcher(testResult => outputText(testResult) || '')
+      if (!allGroups) {
+        selectedItems.selectedTestGroupColor = getComputedStyle(target).getPropertyValue('background-color')
+        selectedItems.testResults = target
+        target.classList.add("selected")
       }
-      const testResults = /**@type {TestResults}*/ data[testGroupId]['TestResults']
-      let testGroupList = /**@type {string}*/ ''
-      selectedItems.selectedTestGroupColor = getComputedStyle(target).getPropertyValue('background-color')
-      selectedItems.testResults = target
-      target.classList.add("selected")
-      for (let i = 0; i < testResults.length; i++) {
-        const testResult = /**@type {TestGroupData}*/ testResults[i]
-        const testPassed = /**@type {boolean}*/ testResult.Passed
-        const testSkipped = /**@type {boolean}*/ testResult.Skipped
-        const testPassedStatus = /**@type {string}*/ (testPassed) ? '' : (testSkipped ? 'skipped' : 'failed')
-        const testStatus = /**@type {string}*/ (testPassed) ? 'PASS' : (testSkipped ? 'SKIP' : 'FAIL')
-        const testId = /**@type {string}*/ target.attributes['id'].value
-        const copyURL = window.location.origin + window.location.pathname + "?testcase=" + testResult.TestName
-        if (testCaseFilter == undefined || testCaseFilter.includes(testStatus)) {
-          testGroupList += `<div id=${testResult.TestName} class="testGroupRow ${testPassedStatus}" data-groupid="${testId}" data-index="${i}">
-        <span class="testTextStatus ${testPassedStatus}">${testStatus}</span>
-        <span class="testStatus ${testPassedStatus}">${(testPassed) ? '&check' : (testSkipped ? '&dash' : '&cross')};</span>
-        <span class="testTitle">${testResult.TestName}</span>
-        <span class="testDuration"><span class="shareLink" id=${copyURL} onClick='copyTestcaseURL("${copyURL}")'>🔗</span><span >${testResult.ElapsedTime}s </span>⏱</span>
-      </div>`
+      list.rows = []
+      list.expanded = new Map()
+      for (const testId of testGroupIds) {
+        const testResults = /**@type {Array.<TestStatus>}*/ data[testId]['TestResults'] || []
+        numOfTests += testResults.length
+        for (let i = 0; i < testResults.length; i++) {
+          const testResult = /**@type {TestStatus}*/ testResults[i]
+          const testStatus = /**@type {string}*/ testResult.Passed ? 'PASS' : (testResult.Skipped ? 'SKIP' : (testResult.KnownFailure != null ? 'KNOWN' : 'FAIL'))
+          if ((testCaseFilter == undefined || testCaseFilter.includes(testStatus)) && matchesPackage(testResult) && matchesSearch != null && matchesSearch(testResult)) {
+            list.rows.push({ groupId: testId, index: i, testResult: testResult })
+          }
         }
-
       }
-      if (testGroupList === '') {
-        testGroupList += `<div <div style="padding-top: 50px;margin-left: 45%;"><span class="">No ${testCaseFilter} testcase</span></div>`
+      if (elements.searchCountElem) {
+        elements.searchInputElem.classList.toggle('invalid', matchesSearch == null)
+        elements.searchCountElem.textContent = matchesSearch == null
+          ? 'invalid regular expression'
+          : `${list.rows.length} of ${numOfTests} tests`
       }
       const testGroupListElem = elements.testGroupListElem
-      testGroupListElem.innerHTML = ''
-      testGroupListElem.innerHTML = testGroupList
-
-      if (shiftKey) {
-        testGroupListElem.querySelectorAll('.testGroupRow')
-          .forEach((elem) => testGroupListHandler(elem, data))
-      } else if (testResults.length === 1) {
-        testGroupListHandler(testGroupListElem.querySelector('.testGroupRow'), data)
+      testGroupListElem.scrollTop = 0
+      if (list.rows.length === 0) {
+        testGroupListElem.innerHTML = `<div class="listMessage">No ${testCaseSearch !== '' || testCasePackage !== '' ? 'matching' : escapeHTML(testCaseFilter.join(','))} testcase</div>`
+        return
       }
+      if (shiftKey || list.rows.length === 1) {
+        list.rows.forEach((row, rowIndex) => list.expanded.set(rowIndex, 0))
+      }
+      renderTestList()
     },
 
     /**
-     *
+     * Expands or collapses the output of a test of the list.
      * @param {Element} target
      * @param {TestResults} data
      */
     testGroupListHandler: function (target, data) {
       const attribs = target['attributes']
-      if (attribs.hasOwnProperty('data-groupid')) {
-        const groupId = /**@type {number}*/ attribs['data-groupid'].value
-        const testIndex = /**@type {number}*/ attribs['data-index'].value
-        const testStatus = /**@type {TestStatus}*/ data[groupId]['TestResults'][testIndex]
-        const testOutputDiv = /**@type {HTMLDivElement}*/ target.querySelector('div.testOutput')
-
-        if (testOutputDiv == null) {
-          const testOutputDiv = document.createElement('div')
-          testOutputDiv.classList.add('testOutput')
-          const consolePre = document.createElement('pre')
-          consolePre.classList.add('console')
-          const testDetailDiv = document.createElement('div')
-          testDetailDiv.classList.add('testDetail')
-          const packageNameDiv = document.createElement('div')
-          packageNameDiv.classList.add('package')
-          packageNameDiv.innerHTML = `<strong>Package:</strong> ${testStatus.Package}`
-          const screenshotDiv = document.createElement('div')
-          screenshotDiv.classList.add('package')
-          if (testStatus.Screenshots && testStatus.Screenshots.length > 0) {
-            const ul = document.createElement('ul')
-            testStatus.Screenshots.forEach(element => {
-              var li = document.createElement('li');
-              var link = document.createElement('a');
-              link.setAttribute("href", element);
-              link.setAttribute("target", "_blank");
-              var linkText = document.createTextNode(element);
-              link.appendChild(linkText);
-              li.appendChild(link);
-              ul.appendChild(li);
-            });
-            screenshotDiv.innerHTML = `<strong>Screenshots:</strong>`
-            screenshotDiv.append(ul)
-          }
-          const testFileNameDiv = document.createElement('div')
-          testFileNameDiv.classList.add('filename')
-          if (testStatus.TestFileName.trim() === "") {
-            testFileNameDiv.innerHTML = `<strong>Filename:</strong> n/a &nbsp;&nbsp;`
-          } else {
-            testFileNameDiv.innerHTML = `<strong>Filename:</strong> ${testStatus.TestFileName} &nbsp;&nbsp;`
-            testFileNameDiv.innerHTML += `<strong>Line:</strong> ${testStatus.TestFunctionDetail.Line} `
-            testFileNameDiv.innerHTML += `<strong>Col:</strong> ${testStatus.TestFunctionDetail.Col}`
-          }
-          testDetailDiv.insertAdjacentElement('beforeend', screenshotDiv)
-          testDetailDiv.insertAdjacentElement('beforeend', packageNameDiv)
-          testDetailDiv.insertAdjacentElement('beforeend', testFileNameDiv)
-          testOutputDiv.insertAdjacentElement('afterbegin', consolePre)
-          testOutputDiv.insertAdjacentElement('beforeend', testDetailDiv)
-          target.insertAdjacentElement('beforeend', testOutputDiv)
-
-          if (testStatus.Passed) {
-            consolePre.classList.remove('skipped')
-            consolePre.classList.remove('failed')
-          } else if (testStatus.Skipped) {
-            consolePre.classList.add('skipped')
-            consolePre.classList.remove('failed')
-          } else {
-            consolePre.classList.remove('skipped')
-            consolePre.classList.add('failed')
-          }
-          consolePre.textContent = testStatus.Output.join('')
+      if (attribs.hasOwnProperty('data-row')) {
+        const rowIndex = Number(attribs['data-row'].value)
+        if (list.rows[rowIndex] === undefined) {
+          return
+        }
+        if (list.expanded.has(rowIndex)) {
+          list.expanded.delete(rowIndex)
         } else {
-          testOutputDiv.remove()
+          list.expanded.set(rowIndex, 0)
         }
+        renderTestList()
       }
     }
   }
 
+  renderTrendCharts(elements.history, elements.trendChartsElem)
+
+  readQueryString()
+  document.querySelectorAll('.packageTree .packageName').forEach((elem) => {
+    elem.classList.toggle('selected', elem.getAttribute('data-path') === testCasePackage)
+    elem.addEventListener('click', event => {
+      // the names are in the summary of a details element, which would toggle
+      event.preventDefault()
+      FilterPackage(elem.getAttribute('data-path'))
+    })
+  })
+  document.querySelectorAll('[data-filter]').forEach((elem) => {
+    elem.addEventListener('click', () => Filter(elem.getAttribute('data-filter').split(',')))
+  })
+  if (elements.searchInputElem) {
+    elements.searchInputElem.value = testCaseSearch
+    elements.searchRegexElem.checked = testCaseSearchRegex
+    const search = () => Search(elements.searchInputElem.value, elements.searchRegexElem.checked)
+    elements.searchInputElem.addEventListener('input', search)
+    elements.searchRegexElem.addEventListener('change', search)
+  }
+
   //+------------------------+
   //|    setup DOM events    |
   //+------------------------+
@@ -219,19 +877,119 @@ window.GoTestReport = function (elements) {
         goTestReport.testGroupListHandler))
 
   elements.testGroupListElem
-    .addEventListener('click', event =>
+    .addEventListener('click', event => {
+      const link = event.target.closest ? event.target.closest('a.shareLink, a.packageLink, a.failureLink') : null
+      if (link != null) {
+        event.preventDefault()
+        followLink(link)
+        return
+      }
       goTestReport.testGroupListHandler(/**@type {Element}*/ event.target,
-        elements.data))
-  window.onload = function () {
-    const queryString = window.location.search;
-    const urlParams = new URLSearchParams(queryString);
-    const testcase = urlParams.get('testcase')
-    if (testcase) {
-      target = document.getElementById(testcase);
-      goTestReport.testGroupListHandler(/**@type {Element}*/ target,
         elements.data)
-      target.scrollIntoView();
-    }
-  };
+    })
+  elements.testGroupListElem.addEventListener('scroll', scheduleRender)
+  window.addEventListener('resize', scheduleRender)
+
+  goTestReport.showTest = showTest
   return goTestReport
 }
+
+/**
+ * Escapes text for HTML content and quoted attribute values.
+ * @param {*} text
+ * @returns {string}
+ */
+function escapeHTML(text) {
+  const entities = { '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&#34;', '\'': '&#39;' }
+  return String(text).replace(/[&<>"']/g, c => entities[c])
+}
+
+/**
+ * Reports whether a link taken from the test output can be opened safely, i.e. it is an http(s)
+ * URL or a relative path rather than e.g. a javascript: URL.
+ * @param {string} url
+ * @returns {boolean}
+ */
+function isSafeURL(url) {
+  return /^https?:\/\//i.test(url) || !/^[^/?#]*:/.test(url)
+}
+
+/**
+ * Copies a link to the clipboard.
+ * @param {string} testcaseURL
+ */
+function copyTestcaseURL(testcaseURL) {
+  navigator.clipboard.writeText(testcaseURL).then(() => {
+    alert("Testcase URL copied to clipboard")
+  })
+}
+
+/**
+ * Decodes and decompresses base64 encoded gzip data.
+ * @param {string} base64
+ * @returns {Promise.<string>}
+ */
+function decompressBase64(base64) {
+  const binary = atob(base64)
+  const bytes = new Uint8Array(binary.length)
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i)
+  }
+  const stream = new Blob([bytes]).stream().pipeThrough(new DecompressionStream('gzip'))
+  return new Response(stream).text()
+}
+
+/**
+ * Returns the text of the escaped HTML of a test output, without its color spans.
+ * @param {string} html
+ * @returns {string}
+ */
+function htmlText(html) {
+  const entities = { '&lt;': '<', '&gt;': '>', '&#34;': '"', '&#39;': '\'', '&amp;': '&' }
+  return html.replace(/<a class="failureLink"[^>]*>#<\/a>/g, '').replace(/<[^>]*>/g, '').replace(/&(lt|gt|#34|#39|amp);/g, entity => entities[entity])
+}
+
+/**
+ * Fetches and decodes JSON.
+ * @param {string} url
+ * @returns {Promise.<*>}
+ */
+function fetchJSON(url) {
+  return fetch(url).then(response => {
+    if (!response.ok) {
+      throw new Error(`${url}: ${response.status} ${response.statusText}`)
+    }
+    return response.json()
+  })
+}
+
+/**
+ * Shows the report once its test results are loaded.
+ * @param {Promise.<{groups: TestResults, outputBlocks: Array.<string>, outputFiles: Array.<string>}>} reportData
+ * The test groups and their output blocks, either embedded or fetched.
+ * @param {GoTestReportElements} elements The elements of the report, without the data.
+ * @returns {Promise.<void>}
+ */
+window.GoTestReport.load = function (reportData, elements) {
+  return reportData.then(reportData => {
+    elements.data = reportData.groups || []
+    elements.outputBlocks = reportData.outputBlocks
+    elements.outputFiles = reportData.outputFiles
+    const goTestReport = window.GoTestReport(elements)
+    elements.testResultsElem.click()
+    const params = new URLSearchParams(window.location.search)
+    const testcase = params.get('testcase')
+    const failure = params.has('failure') ? Number(params.get('failure')) : null
+    if (testcase) {
+      return goTestReport.showTest(testcase, failure).then(shown => {
+        if (shown) {
+          elements.testGroupListElem.scrollIntoView()
+        }
+      })
+    }
+  }).catch(err => {
+    // split reports are fetched, which browsers do not allow for local files
+    elements.testGroupListElem.innerHTML = '<div class="listMessage">The test results could not be loaded. Reports written with --split must be served by a web server.</div>'
+    console.error(err)
+  })
+}
@@ -1,4 +1,6 @@
 const expect = require('@jest/globals').expect
+const fs = require('fs')
+const path = require('path')
 require('./test_report.js')
 
 /**
@@ -7,13 +9,11 @@ require('./test_report.js')
 const mockData = [
   {
     "TestResults": [{
+      ID: "test-package-1-my_sample_test-1",
       TestName: "my_sample_test 1",
       Package: "test/package 1",
-      Output: [
-        "test output A 1\n",
-        "test output A 2\n",
-        "test output A 3\n",
-      ],
+      OutputBlock: 0,
+      OutputIndex: 0,
       TestFileName: "test_test.go",
       TestFunctionDetail: {
         Line: 1,
@@ -22,28 +22,24 @@ const mockData = [
     }]
   }, {
     "TestResults": [{
+      ID: "test-package-2-my_sample_test-2",
       TestName: "my_sample_test 2",
       Package: "test/package 2",
       Passed: true,
-      Output: [
-        "test output B 1\n",
-        "test output B 2\n",
-        "test output B 3\n",
-      ],
+      OutputBlock: 0,
+      OutputIndex: 1,
       TestFileName: "test_test_1.go",
       TestFunctionDetail: {
         Line: 20,
         Col: 1,
       },
     }, {
+      ID: "test-package-3-my_sample_test-3",
       TestName: "my_sample_test 3",
       Package: "test/package 3",
       Passed: false,
-      Output: [
-        "test output C 1\n",
-        "test output C 2\n",
-        "test output C 3\n",
-      ],
+      OutputBlock: 0,
+      OutputIndex: 2,
       TestFileName: "test_test_2.go",
       TestFunctionDetail: {
         Line: 33,
@@ -52,14 +48,12 @@ const mockData = [
     }]
   }, {
     "TestResults": [{
+      ID: "test-package-4-my_sample_test-4",
       TestName: "my_sample_test 4",
       Package: "test/package 4",
       Passed: true,
-      Output: [
-        "test output D 1\n",
-        "test output D 2\n",
-        "test output D 3\n",
-      ],
+      OutputBlock: 0,
+      OutputIndex: 3,
       TestFileName: "test_test_3.go",
       TestFunctionDetail: {
         Line: 101,
@@ -68,8 +62,33 @@ const mockData = [
     }]
   }]
 
+/**
+ * The outputs of the tests by output block, as escaped HTML.
+ */
+const mockOutputs = {
+  "outputs_0.json": [
+    "test output A 1\ntest output A 2\ntest output A 3\n",
+    "test output B 1\ntest output B 2\ntest output B 3\n",
+    "test output C 1\ntest output C 2\ntest output C 3\n",
+    "test output D 1\ntest output D 2\ntest output D 3\n",
+  ]
+}
+
+// the outputs are fetched as for a report written with --split
+global.fetch = url => Promise.resolve({
+  ok: true,
+  json: () => Promise.resolve(mockOutputs[url]),
+})
+
+/**
+ * Waits until the fetched outputs are rendered.
+ * @returns {Promise.<void>}
+ */
+function outputsLoaded() {
+  return new Promise(resolve => setTimeout(resolve, 0))
+}
 
-function createTestElements() {
+function createTestElements(data = mockData) {
   const testResultsElem = document.createElement('div')
   testResultsElem.id = 'testResults'
   const testGroupListElem = document.createElement('div')
@@ -78,15 +97,17 @@ function createTestElements() {
   testGroupListElem.id = 'testGroupList'
 
   let counter = 0
-  mockData.forEach((_) => {
+  data.forEach((_) => {
     let testResultGroup = document.createElement('div')
     testResultGroup.id = counter.toString()
+    testResultGroup.classList.add('testResultGroup')
     counter += 1
     testResultsElem.insertAdjacentElement("beforeend", testResultGroup)
   })
 
   return {
-    data: mockData,
+    data: data,
+    outputFiles: ["outputs_0.json"],
     testResultsElem: testResultsElem,
     testGroupListElem: testGroupListElem
   }
@@ -102,7 +123,7 @@ test('test GoTestReport constructor with click event on a test group', () => {
                                                    selectedItems,
                                                    testGroupListHandler) {
     expect(true).toBe(true)
-    expect(target.outerHTML).toBe(`<div id="0"></div>`)
+    expect(target.outerHTML).toBe(`<div id="0" class="testResultGroup"></div>`)
     expect(shiftKey).toBe(false)
     expect(data).toBe(mockData)
     expect(selectedItems.testResults).toBeNull()
@@ -118,48 +139,172 @@ test('test GoTestReport constructor with click event on a test group', () => {
   expect(invocationCounts.testResultsClickHandler).toBe(1)
 })
 
-
 /**
- * Returns an element using the provided testGroupId and testIndex.
+ * Lists the tests of a test group. The test of a group with a single test is expanded right away,
+ * otherwise the test in the given row of the list is expanded.
  * @param {number} testGroupId
- * @param {number} testIndex
- * @returns {HTMLDivElement}
+ * @param {number} rowIndex
+ * @returns {{goTestReport: *, testGroupListElem: HTMLElement}}
  */
-function createDataGroupElement(testGroupId, testIndex) {
-  const divElem = document.createElement('div')
-  const testGroupIdAttr = document.createAttribute('data-groupid')
-  testGroupIdAttr.value = testGroupId.toString()
-  const indexAttr = document.createAttribute('data-index')
-  indexAttr.value = testIndex.toString()
-  divElem.attributes.setNamedItem(testGroupIdAttr)
-  divElem.attributes.setNamedItem(indexAttr)
-  return divElem
+function expandTest(testGroupId, rowIndex) {
+  const testElements = createTestElements()
+  const goTestReport = window.GoTestReport(testElements)
+  testElements.testResultsElem.querySelector(`[id="${testGroupId}"]`).click()
+  if (mockData[testGroupId].TestResults.length > 1) {
+    const rowElem = testElements.testGroupListElem.querySelector(`[data-row="${rowIndex}"]`)
+    goTestReport.testGroupListHandler(rowElem, mockData)
+  }
+  return { goTestReport: goTestReport, testGroupListElem: testElements.testGroupListElem }
 }
 
-test('test testGroupListHandler using [test group: 0]', () => {
-  const goTestReport = new window.GoTestReport(createTestElements());
-  let divElem = createDataGroupElement(0, 0)
-  goTestReport.testGroupListHandler(divElem, mockData)
-  const testOutputDiv = divElem.querySelector('div.testOutput')
+test('test testResultsClickHandler lists the tests of a group', () => {
+  const testElements = createTestElements()
+  window.GoTestReport(testElements)
+  testElements.testResultsElem.querySelector('[id="1"]').click()
+  const rows = testElements.testGroupListElem.querySelectorAll('.testGroupRow')
+  expect(rows).toHaveLength(2)
+  expect(rows[0].id).toBe('test-package-2-my_sample_test-2')
+  expect(rows[0].getAttribute('data-row')).toBe('0')
+  expect(rows[0].querySelector('.testTitle').textContent).toBe('my_sample_test 2')
+  expect(rows[1].classList.contains('failed')).toBe(true)
+  expect(rows[1].querySelector('.testTextStatus').textContent).toBe('FAIL')
+  // the outputs are only rendered once a test is expanded
+  expect(testElements.testGroupListElem.querySelector('div.testOutput')).toBeNull()
+})
+
+test('test testGroupListHandler using [test group: 0]', async () => {
+  const { testGroupListElem } = expandTest(0, 0)
+  await outputsLoaded()
+  const testOutputDiv = testGroupListElem.querySelector('[data-row="0"] div.testOutput')
   const consoleElem = testOutputDiv.querySelector('.console.failed')
   expect(consoleElem.textContent).toBe('test output A 1\ntest output A 2\ntest output A 3\n')
   const testDetailElem = testOutputDiv.querySelector('.testDetail')
-  const packageElem = testDetailElem.querySelector('.package')
-  expect(packageElem.innerHTML).toBe(`<strong>Package:</strong> test/package 1`)
+  const packageLink = testDetailElem.querySelector('.package a.packageLink')
+  expect(packageLink.textContent).toBe('test/package 1')
+  expect(packageLink.getAttribute('href')).toBe('?pkg=test%2Fpackage%201')
   const filenameElem = testDetailElem.querySelector('.filename')
   expect(filenameElem.innerHTML).toBe(`<strong>Filename:</strong> test_test.go &nbsp;&nbsp;<strong>Line:</strong> 1 <strong>Col:</strong> 10`)
 })
 
-test('test testGroupListHandler using [test group: 1]', () => {
-  const goTestReport = new window.GoTestReport(createTestElements());
-  let divElem = createDataGroupElement(2, 0)
-  goTestReport.testGroupListHandler(divElem, mockData)
-  const testOutputDiv = divElem.querySelector('div.testOutput')
+test('test testGroupListHandler using [test group: 2]', async () => {
+  const { testGroupListElem } = expandTest(2, 0)
+  await outputsLoaded()
+  const testOutputDiv = testGroupListElem.querySelector('[data-row="0"] div.testOutput')
   const consoleElem = testOutputDiv.querySelector('.console')
+  expect(consoleElem.classList.contains('failed')).toBe(false)
   expect(consoleElem.textContent).toBe('test output D 1\ntest output D 2\ntest output D 3\n')
   const testDetailElem = testOutputDiv.querySelector('.testDetail')
-  const packageElem = testDetailElem.querySelector('.package')
-  expect(packageElem.innerHTML).toBe(`<strong>Package:</strong> test/package 4`)
+  const packageLink = testDetailElem.querySelector('.package a.packageLink')
+  expect(packageLink.textContent).toBe('test/package 4')
   const filenameElem = testDetailElem.querySelector('.filename')
   expect(filenameElem.innerHTML).toBe(`<strong>Filename:</strong> test_test_3.go &nbsp;&nbsp;<strong>Line:</strong> 101 <strong>Col:</strong> 9`)
-})
\ No newline at end of file
+})
+
+test('test testGroupListHandler collapses an expanded test', async () => {
+  const { goTestReport, testGroupListElem } = expandTest(1, 1)
+  await outputsLoaded()
+  expect(testGroupListElem.querySelector('[data-row="1"] div.testOutput')).not.toBeNull()
+  expect(testGroupListElem.querySelector('[data-row="0"] div.testOutput')).toBeNull()
+  goTestReport.testGroupListHandler(testGroupListElem.querySelector('[data-row="1"]'), mockData)
+  expect(testGroupListElem.querySelector('div.testOutput')).toBeNull()
+})
+
+/**
+ * Escapes an output and removes its colors, as the report does before embedding it.
+ * @param {string} output
+ * @returns {string}
+ */
+function escapeOutput(output) {
+  const entities = { '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&#34;', '\'': '&#39;' }
+  return output.replace(/\x1b\[[0-9;]*m/g, '').replace(/[&<>"']/g, c => entities[c])
+}
+
+/**
+ * Returns the tests of testdata/hostile_names.json in a single test group, with their names,
+ * packages and screenshots as go test reported them. Their outputs are in output block 1.
+ * @returns {{data: TestResults, outputs: Array.<string>}}
+ */
+function hostileTestData() {
+  const events = fs.readFileSync(path.join(__dirname, 'testdata', 'hostile_names.json'), 'utf8')
+    .trim().split('\n').map(line => JSON.parse(line))
+  const tests = new Map()
+  const outputs = []
+  for (const event of events) {
+    if (!event.Test) {
+      continue
+    }
+    if (!tests.has(event.Test)) {
+      tests.set(event.Test, {
+        ID: `hostile-${tests.size}`,
+        TestName: event.Test,
+        Package: event.Package,
+        OutputBlock: 1,
+        OutputIndex: tests.size,
+        Screenshots: [],
+        TestFileName: `${event.Test}_test.go`,
+        TestFunctionDetail: { Line: 1, Col: 1 },
+      })
+      outputs.push('')
+    }
+    const testResult = tests.get(event.Test)
+    if (event.Action === 'output') {
+      outputs[testResult.OutputIndex] += escapeOutput(event.Output)
+      const screenshots = event.Output.match(/Screenshots : \[(.*)\]/)
+      if (screenshots) {
+        testResult.Screenshots.push(...screenshots[1].split(' '))
+      }
+    }
+    testResult.Passed = testResult.Passed || event.Action === 'pass'
+    testResult.Skipped = testResult.Skipped || event.Action === 'skip'
+  }
+  const testResults = [...tests.values()]
+  // the known failures and the truncated outputs link to tickets and files given by the user
+  testResults[0].KnownFailure = { Reason: '<img src=x onerror=alert(1)>', Ticket: 'javascript:alert(1)' }
+  testResults[1].TruncatedBytes = 10
+  testResults[1].FullOutput = 'javascript:alert(1)'
+  return { data: [{ TestResults: testResults }], outputs: outputs }
+}
+
+test('test hostile test names and outputs are rendered as text', async () => {
+  const { data, outputs } = hostileTestData()
+  mockOutputs['outputs_1.json'] = outputs
+  const testElements = createTestElements(data)
+  testElements.outputFiles = ['outputs_0.json', 'outputs_1.json']
+  const goTestReport = window.GoTestReport(testElements)
+  // lists the tests of all groups with their outputs expanded
+  goTestReport.testResultsClickHandler(testElements.testResultsElem, true, data,
+    { testResults: null, selectedTestGroupColor: null }, goTestReport.testGroupListHandler)
+  await outputsLoaded()
+
+  const listElem = testElements.testGroupListElem
+  const rows = listElem.querySelectorAll('.testGroupRow')
+  expect(rows).toHaveLength(4)
+  expect(listElem.querySelectorAll('div.testOutput')).toHaveLength(4)
+  expect(listElem.querySelectorAll('img, svg, script')).toHaveLength(0)
+  listElem.querySelectorAll('*').forEach(elem => {
+    elem.getAttributeNames().forEach(name => {
+      expect(name).toMatch(/^(id|class|href|target|title|data-[a-z]+)$/)
+    })
+  })
+  listElem.querySelectorAll('a').forEach(link => {
+    expect(link.getAttribute('href')).not.toMatch(/^\s*javascript:/i)
+  })
+
+  data[0].TestResults.forEach((testResult, i) => {
+    const row = rows[i]
+    expect(row.id).toBe(testResult.ID)
+    expect(row.querySelector('.testTitle').textContent).toBe(testResult.TestName)
+    const packageLink = row.querySelector('.package a.packageLink')
+    expect(packageLink.textContent).toBe(testResult.Package)
+    expect(packageLink.getAttribute('data-package')).toBe(testResult.Package)
+    expect(row.querySelector('.filename').textContent).toContain(testResult.TestFileName)
+  })
+  expect(rows[0].querySelector('.console').textContent).toBe('    evil_test.go:3: <script>alert(1)</script>\n')
+  expect(rows[0].querySelector('.knownFailure').textContent).toBe('Known failure: <img src=x onerror=alert(1)> javascript:alert(1)')
+  expect(rows[1].querySelector('.truncated a')).toBeNull()
+  expect(rows[2].querySelector('.console').textContent).toBe('</pre><svg onload=alert(1)>\n')
+  // only the relative screenshot link is rendered, not the javascript: one
+  const screenshots = rows[3].querySelectorAll('.package ul a')
+  expect(screenshots).toHaveLength(1)
+  expect(screenshots[0].getAttribute('href')).toBe('shots/ok.png')
+})